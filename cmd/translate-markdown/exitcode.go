@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ariela/translate-markdown/internal/app"
+)
+
+// 終了コードです。
+const (
+	// exitOKは全てのファイルの翻訳に成功したことを表します。
+	exitOK = 0
+	// exitErrorは設定の誤りや認証エラーなど、翻訳を続けられないエラーを表します。
+	exitError = 1
+	// exitFailedは一部のファイルの翻訳に失敗したことを表します。
+	exitFailed = 2
+	// exitQuotaはDeepLの文字数の上限に達したことを表します。
+	exitQuota = 3
+	// exitInterruptedはCtrl-Cなどで処理を中断したことを表します。
+	exitInterrupted = 130
+)
+
+// --fail-onに指定できる値です。
+const (
+	// failOnSkippedは失敗したファイルに加え、文字数の上限により翻訳を持ち越したファイルがある場合も失敗とします。
+	failOnSkipped = "skipped"
+	// failOnFailedは失敗したファイルがある場合に失敗とします。
+	failOnFailed = "failed"
+	// failOnNeverはファイルの処理結果によらず成功とします。
+	failOnNever = "never"
+)
+
+// validateFailOnは--fail-onの値が正しいかを確認します。
+func validateFailOn(policy string) error {
+	switch policy {
+	case failOnSkipped, failOnFailed, failOnNever:
+		return nil
+	}
+	return fmt.Errorf("invalid --fail-on %q (expected %q, %q or %q)", policy, failOnSkipped, failOnFailed, failOnNever)
+}
+
+// exitCodeは完了レポートと--fail-onの設定から終了コードを決定します。
+// 中断した場合は--fail-onによらずexitInterruptedを返します。
+func exitCode(report *app.Report, interrupted bool, policy string) int {
+	if interrupted {
+		return exitInterrupted
+	}
+	if policy == failOnNever {
+		return exitOK
+	}
+
+	failedWith := func(category string) int {
+		return report.CountFiles(func(f app.FileResult) bool {
+			return f.Status == app.StatusFailed && f.ErrorCategory == category
+		})
+	}
+	switch {
+	case failedWith(app.CategoryConfig) > 0 || failedWith(app.CategoryAuth) > 0:
+		return exitError
+	case failedWith(app.CategoryQuota) > 0:
+		return exitQuota
+	case report.FailedCount > 0:
+		return exitFailed
+	}
+
+	if policy == failOnSkipped {
+		deferred := report.CountFiles(func(f app.FileResult) bool {
+			return f.Status == app.StatusSkipped && f.SkipReason == app.SkipBudget
+		})
+		if deferred > 0 {
+			return exitFailed
+		}
+	}
+	return exitOK
+}
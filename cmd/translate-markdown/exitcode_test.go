@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ariela/translate-markdown/internal/app"
+)
+
+func TestExitCode(t *testing.T) {
+	success := app.FileResult{Source: "a.md", Status: app.StatusSuccess}
+	unchanged := app.FileResult{Source: "b.md", Status: app.StatusSkipped, SkipReason: app.SkipUnchanged}
+	deferred := app.FileResult{Source: "c.md", Status: app.StatusSkipped, SkipReason: app.SkipBudget}
+	failed := func(category string) app.FileResult {
+		return app.FileResult{Source: "d.md", Status: app.StatusFailed, Err: errors.New(category), ErrorCategory: category}
+	}
+
+	tests := []struct {
+		name        string
+		files       []app.FileResult
+		interrupted bool
+		policy      string
+		want        int
+	}{
+		{name: "all succeeded", files: []app.FileResult{success, unchanged}, policy: failOnFailed, want: exitOK},
+		{name: "no files", policy: failOnSkipped, want: exitOK},
+		{name: "config error", files: []app.FileResult{success, failed(app.CategoryConfig)}, policy: failOnFailed, want: exitError},
+		{name: "auth error", files: []app.FileResult{failed(app.CategoryAuth)}, policy: failOnFailed, want: exitError},
+		{name: "auth error wins over quota", files: []app.FileResult{failed(app.CategoryQuota), failed(app.CategoryAuth)}, policy: failOnFailed, want: exitError},
+		{name: "failed file", files: []app.FileResult{success, failed(app.CategoryValidation)}, policy: failOnFailed, want: exitFailed},
+		{name: "network error", files: []app.FileResult{failed(app.CategoryNetwork)}, policy: failOnSkipped, want: exitFailed},
+		{name: "quota exceeded", files: []app.FileResult{failed(app.CategoryQuota), failed(app.CategoryServer)}, policy: failOnFailed, want: exitQuota},
+		{name: "deferred file with fail-on failed", files: []app.FileResult{success, deferred}, policy: failOnFailed, want: exitOK},
+		{name: "deferred file with fail-on skipped", files: []app.FileResult{success, deferred}, policy: failOnSkipped, want: exitFailed},
+		{name: "unchanged file with fail-on skipped", files: []app.FileResult{unchanged}, policy: failOnSkipped, want: exitOK},
+		{name: "fail-on never", files: []app.FileResult{failed(app.CategoryAuth), deferred}, policy: failOnNever, want: exitOK},
+		{name: "interrupted", files: []app.FileResult{success}, interrupted: true, policy: failOnFailed, want: exitInterrupted},
+		{name: "interrupted wins over failures", files: []app.FileResult{failed(app.CategoryQuota)}, interrupted: true, policy: failOnNever, want: exitInterrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := app.NewReport()
+			for _, f := range tt.files {
+				report.AddFile(f)
+			}
+			if got := exitCode(report, tt.interrupted, tt.policy); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFailOn(t *testing.T) {
+	for _, policy := range []string{failOnSkipped, failOnFailed, failOnNever} {
+		if err := validateFailOn(policy); err != nil {
+			t.Errorf("validateFailOn(%q) = %v", policy, err)
+		}
+	}
+	for _, policy := range []string{"", "always", "Failed"} {
+		if err := validateFailOn(policy); err == nil {
+			t.Errorf("validateFailOn(%q) succeeded, want error", policy)
+		}
+	}
+}
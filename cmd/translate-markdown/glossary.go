@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ariela/translate-markdown/internal/app"
+)
+
+// glossaryCmdは用語集を管理するコマンドを表します。
+var glossaryCmd = &cobra.Command{
+	Use:   "glossary",
+	Short: "Manage DeepL glossaries defined in the configuration file.",
+}
+
+// glossarySyncCmdは設定ファイルの用語集をDeepLに同期するコマンドを表します。
+var glossarySyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Create or replace DeepL glossaries from the glossary files.",
+	Long: `sync uploads each glossary file listed in the configuration file to DeepL.
+A glossary is only recreated when the file content has changed, and older
+glossaries for the same language pair are deleted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, deeplClient, logFile := setup()
+		defer logFile.Close()
+
+		if len(cfg.Glossaries) == 0 {
+			fmt.Println("No glossaries defined in the configuration file.")
+			return
+		}
+
+		results, err := app.SyncGlossaries(cmd.Context(), deeplClient, cfg.Glossaries)
+		for _, r := range results {
+			status := "unchanged"
+			if r.Created {
+				status = "created"
+			}
+			fmt.Printf("%s (%s): %s %s\n", r.File, r.Pair, status, r.ID)
+			for _, id := range r.Deleted {
+				fmt.Printf("  deleted old glossary %s\n", id)
+			}
+		}
+		if err != nil {
+			slog.Error("Failed to sync glossaries", "error", err)
+			os.Exit(exitError)
+		}
+	},
+}
+
+func init() {
+	glossaryCmd.AddCommand(glossarySyncCmd)
+	rootCmd.AddCommand(glossaryCmd)
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"syscall"
 
 	"github.com/spf13/cobra"
 
@@ -19,6 +24,14 @@ var (
 	configPath string
 	force      bool
 	parallel   int
+	dryRun     bool
+
+	reportFormat string
+	reportFile   string
+	failOn       string
+
+	// exitStatusはルートコマンドの終了コードです。遅延処理を実行した後で、mainが終了コードとして使用します。
+	exitStatus = exitOK
 )
 
 // rootCmdはアプリケーションのルートコマンドを表します。
@@ -28,63 +41,166 @@ var rootCmd = &cobra.Command{
 	Long: `translate-markdown is a command-line tool that translates Markdown files
 while preserving the structure, such as code blocks and frontmatter.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		// プロジェクトルートとログファイルのパスを設定
-		projectRoot := filepath.Dir(configPath)
-		logFilePath := filepath.Join(projectRoot, "translate-errors.log")
-
-		// ログファイルを開く
-		logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-		if err != nil {
-			log.Fatalf("Failed to open log file: %v", err)
-		}
+		ctx := cmd.Context()
+		cfg, deeplClient, logFile := setup()
 		defer logFile.Close()
 
-		// ロガーを初期化
-		logger := setupLogger(logFile)
-		slog.SetDefault(logger)
-
-		// 設定ファイルを読み込む
-		cfg, err := app.LoadConfig(configPath)
-		if err != nil {
-			slog.Error("Error loading config", "error", err)
-			os.Exit(1)
+		if err := app.ValidateReportFormat(reportFormat); err != nil {
+			slog.Error("Invalid flag", "error", err)
+			os.Exit(exitError)
 		}
-
-		// APIキーを環境変数から取得
-		apiKey := os.Getenv("DEEPL_AUTH_KEY")
-		if apiKey == "" {
-			slog.Error("DEEPL_AUTH_KEY environment variable not set.")
-			os.Exit(1)
+		if err := validateFailOn(failOn); err != nil {
+			slog.Error("Invalid flag", "error", err)
+			os.Exit(exitError)
 		}
 
-		// DeepLクライアントと翻訳クライアントを初期化
-		deeplClient := deepl.NewClient(apiKey, logger)
-		translator, err := app.NewTranslator(deeplClient, projectRoot, force, parallel)
+		// 翻訳クライアントを初期化
+		translator, err := app.NewTranslator(deeplClient, filepath.Dir(configPath), force, parallel)
 		if err != nil {
 			slog.Error("Failed to create translator", "error", err)
-			os.Exit(1)
+			os.Exit(exitError)
+		}
+		translator.SetProgressOutput(progressOutput())
+
+		// ドライランではAPIを呼び出さないため、用語集IDは取得しない
+		if dryRun {
+			translator.EnableDryRun()
+		} else {
+			// 用語集が設定されている場合は、同期済みの用語集IDを取得
+			glossaryIDs, err := app.ResolveGlossaries(ctx, deeplClient, cfg.Glossaries)
+			if err != nil {
+				slog.Error("Failed to resolve glossaries", "error", err)
+				os.Exit(exitError)
+			}
+			translator.UseGlossaries(glossaryIDs)
+
+			// 残りの文字数を確認し、不足する場合は翻訳するファイルを制限する
+			translator.UseQuotaPlan(planQuota(ctx, cfg, deeplClient))
 		}
 
 		// 全てのジョブを実行
+		// 中断された場合は残りのジョブを実行せず、完了したファイルのキャッシュを保存してレポートを出力する
 		for _, job := range cfg.Jobs {
+			if ctx.Err() != nil {
+				break
+			}
 			slog.Info("Executing job", "source", job.Source)
-			err := translator.TranslateJob(job, cfg)
+			err := translator.TranslateJob(ctx, job, cfg)
+			if errors.Is(err, context.Canceled) {
+				break
+			}
 			if err != nil {
 				translator.Report.AddError(job.Source, err)
 				slog.Warn("Error processing job", "source", job.Source, "error", err)
 			}
 		}
 
+		// ドライランでは見積もりのみを出力し、キャッシュは保存しない
+		if dryRun {
+			translator.Estimate.Print(os.Stdout, cfg.PricePerMillion)
+			translator.Report.PrintErrors()
+			exitStatus = exitCode(translator.Report, ctx.Err() != nil, failOn)
+			return
+		}
+
 		// キャッシュを保存
 		if err := translator.SaveCache(); err != nil {
 			slog.Warn("Failed to save cache", "error", err)
 		}
 
 		// 完了レポートを出力
-		translator.Report.Print()
+		writeReport(translator.Report)
+		if ctx.Err() != nil {
+			fmt.Fprintln(progressOutput(), "\nInterrupted. Completed files have been saved; run again to translate the rest.")
+		}
+		exitStatus = exitCode(translator.Report, ctx.Err() != nil, failOn)
 	},
 }
 
+// writeReportは完了レポートを--report-formatの形式で出力します。
+// --report-fileが指定されている場合は、コンソールにテキストの集計結果を出力し、ファイルに指定された形式で書き込みます。
+func writeReport(report *app.Report) {
+	if reportFile == "" {
+		if err := report.Write(os.Stdout, reportFormat); err != nil {
+			slog.Error("Failed to write report", "error", err)
+		}
+		return
+	}
+
+	report.Print()
+	f, err := os.Create(reportFile)
+	if err != nil {
+		slog.Error("Failed to create report file", "error", err)
+		return
+	}
+	defer f.Close()
+	if err := report.Write(f, reportFormat); err != nil {
+		slog.Error("Failed to write report", "file", reportFile, "error", err)
+	}
+}
+
+// progressOutputは進捗を出力する先を返します。
+// JSONやJUnit XMLのレポートを標準出力に出力する場合は、CIでレポートを解析できるよう標準エラー出力に出力します。
+func progressOutput() io.Writer {
+	if reportFile == "" && reportFormat != app.ReportFormatText {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// setupはロガー、設定ファイル、DeepLクライアントを初期化します。
+// 初期化に失敗した場合はエラーを出力して終了します。
+// 戻り値のログファイルは呼び出し元で閉じる必要があります。
+func setup() (*app.Config, *deepl.Client, *os.File) {
+	// プロジェクトルートとログファイルのパスを設定
+	projectRoot := filepath.Dir(configPath)
+	logFilePath := filepath.Join(projectRoot, "translate-errors.log")
+
+	// ログファイルを開く
+	logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		log.Fatalf("Failed to open log file: %v", err)
+	}
+
+	// ロガーを初期化
+	logger := setupLogger(logFile)
+	slog.SetDefault(logger)
+
+	// 設定ファイルを読み込む
+	cfg, err := app.LoadConfig(configPath)
+	if err != nil {
+		slog.Error("Error loading config", "error", err)
+		os.Exit(exitError)
+	}
+
+	// APIキーを環境変数から取得
+	// ドライランではAPIを呼び出さないため、APIキーは必須としない
+	apiKey := os.Getenv("DEEPL_AUTH_KEY")
+	if apiKey == "" && !dryRun {
+		slog.Error("DEEPL_AUTH_KEY environment variable not set.")
+		os.Exit(exitError)
+	}
+
+	// APIのURLは環境変数、設定ファイルの順に優先し、どちらもなければAPIキーから自動で選択する
+	apiURL := os.Getenv("DEEPL_API_URL")
+	if apiURL == "" {
+		apiURL = cfg.APIURL
+	}
+
+	// DeepLクライアントを初期化
+	clientOpts := []deepl.ClientOption{
+		deepl.WithBaseURL(apiURL),
+		deepl.WithBatchConcurrency(cfg.BatchConcurrency),
+		deepl.WithRequestsPerSecond(cfg.RequestsPerSec),
+		deepl.WithCharsPerMinute(cfg.CharsPerMinute),
+	}
+	if cfg.MaxRetries != nil {
+		clientOpts = append(clientOpts, deepl.WithMaxRetries(*cfg.MaxRetries))
+	}
+	deeplClient := deepl.NewClient(apiKey, logger, clientOpts...)
+	return cfg, deeplClient, logFile
+}
+
 // setupLoggerはデバッグモードに応じてロガーを設定します。
 func setupLogger(logFile io.Writer) *slog.Logger {
 	logLevel := slog.LevelInfo
@@ -114,14 +230,31 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&force, "force", false, "force translation even if the file is not modified")
 	// デフォルトの並列数はCPUのコア数とする
 	rootCmd.PersistentFlags().IntVar(&parallel, "parallel", runtime.NumCPU(), "number of parallel translations")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show characters to be translated and estimated cost without calling the API or writing files")
+	rootCmd.Flags().StringVar(&reportFormat, "report-format", app.ReportFormatText, "format of the run report (text, json or junit)")
+	rootCmd.Flags().StringVar(&reportFile, "report-file", "", "write the run report to this file instead of the console")
+	rootCmd.Flags().StringVar(&failOn, "fail-on", failOnFailed, "when to exit with a nonzero status: failed, skipped (also files deferred by the character budget) or never")
 }
 
 func main() {
 	// slogを使うため、標準のlogの出力を無効化
 	log.SetOutput(io.Discard)
-	if err := rootCmd.Execute(); err != nil {
+
+	// Ctrl-C (SIGINT) またはSIGTERMを受け取った場合は、コンテキストをキャンセルして処理を中断する
+	// 2回目のシグナルでは、通常どおり直ちに終了する
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		// cobraのエラーはslogで出力されないため、ここで明示的に出力
 		slog.Error("Command failed", "error", err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+	if exitStatus != exitOK {
+		os.Exit(exitStatus)
 	}
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ariela/translate-markdown/internal/app"
+	"github.com/ariela/translate-markdown/internal/deepl"
+)
+
+// usageCmdはDeepLアカウントの文字数の使用状況を表示するコマンドを表します。
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show the character usage of the DeepL account.",
+	Run: func(cmd *cobra.Command, args []string) {
+		_, deeplClient, logFile := setup()
+		defer logFile.Close()
+
+		usage, err := deeplClient.Usage(cmd.Context())
+		if err != nil {
+			slog.Error("Failed to get usage", "error", err)
+			os.Exit(exitError)
+		}
+		printUsage(os.Stdout, usage)
+	},
+}
+
+// printUsageは文字数の使用状況をwに出力します。
+func printUsage(w io.Writer, usage deepl.Usage) {
+	var percent float64
+	if usage.CharacterLimit > 0 {
+		percent = float64(usage.CharacterCount) / float64(usage.CharacterLimit) * 100
+	}
+	fmt.Fprintf(w, "Characters used:      %d / %d (%.1f%%)\n", usage.CharacterCount, usage.CharacterLimit, percent)
+	fmt.Fprintf(w, "Characters remaining: %d\n", usage.Remaining())
+}
+
+// planQuotaは翻訳に必要な文字数をドライランで見積もり、アカウントの残りの文字数と比較します。
+// 残りの文字数が不足し、char_budgetも指定されていない場合は見積もりを出力して終了します。
+func planQuota(ctx context.Context, cfg *app.Config, deeplClient *deepl.Client) *app.QuotaPlan {
+	usage, err := deeplClient.Usage(ctx)
+	if err != nil {
+		slog.Error("Failed to check usage", "error", err)
+		os.Exit(exitError)
+	}
+
+	planner, err := app.NewTranslator(deeplClient, filepath.Dir(configPath), force, parallel)
+	if err != nil {
+		slog.Error("Failed to create translator", "error", err)
+		os.Exit(exitError)
+	}
+	planner.EnableDryRun()
+	planner.SetProgressOutput(progressOutput())
+	for _, job := range cfg.Jobs {
+		// ジョブのエラーは翻訳時に改めて報告されるため、ここでは無視する
+		_ = planner.TranslateJob(ctx, job, cfg)
+	}
+
+	// 見積もりや案内はJSONやJUnit XMLのレポートと混ざらないよう、進捗と同じ出力先に出力する
+	w := progressOutput()
+	plan, err := app.PlanQuota(planner.Estimate, usage, cfg.CharBudget)
+	if err != nil {
+		planner.Estimate.Print(w, cfg.PricePerMillion)
+		printUsage(w, usage)
+		fmt.Fprintln(w, "\nThe run was aborted before translating any file.")
+		fmt.Fprintln(w, "Set char_budget in the configuration file to translate only part of the files.")
+		slog.Error("Not enough DeepL quota", "error", err)
+		os.Exit(exitQuota)
+	}
+
+	if len(plan.Deferred) > 0 {
+		fmt.Fprintf(w, "Translating up to %d of %d characters (remaining quota: %d, char_budget: %d).\n",
+			plan.Limit, plan.Required, plan.Remaining, cfg.CharBudget)
+		fmt.Fprintln(w, "The following files will be translated in a later run:")
+		for _, f := range plan.Deferred {
+			fmt.Fprintf(w, "- %s -> %s [%s] (%d chars)\n", f.Source, f.Destination, f.TargetLang, f.Chars)
+		}
+	}
+	return plan
+}
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+}
@@ -7,23 +7,63 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 )
 
-const cacheFileName = ".translation_cache.json"
+const (
+	cacheFileName = ".translation_cache.json"
+	// cacheSchemaVersionはキャッシュファイルの形式のバージョンです。
+	// バージョン1(フィールドなし)はソースのパスごとのMD5ハッシュのみを保持していました。
+	cacheSchemaVersion = 2
+)
+
+// CacheKeyはキャッシュエントリを識別する情報です。
+// 同じソースを複数のジョブで異なる言語や出力先に翻訳しても、それぞれ別のエントリとして扱います。
+type CacheKey struct {
+	Source      string
+	Destination string
+	TargetLang  string
+}
+
+// CacheEntryは翻訳済みファイル1件のキャッシュ情報です。
+type CacheEntry struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	TargetLang  string `json:"target_lang"`
+	// HashはソースファイルのMD5ハッシュです。
+	Hash string `json:"hash"`
+	// Fingerprintは翻訳結果に影響する設定(formality、用語集など)のハッシュです。
+	Fingerprint string `json:"fingerprint"`
+	// OutputHashは出力先に書き込んだ内容のMD5ハッシュです。
+	// ソースが削除された翻訳済みファイルを削除する前に、手動で編集されていないかを確認するために使用します。
+	OutputHash string `json:"output_hash,omitempty"`
+}
 
 // Cacheは翻訳済みファイルのハッシュを保持します。
+// 複数のワーカーから同時に使用できます。
 type Cache struct {
 	path string
-	// キー: ファイルパス, 値: MD5ハッシュ
-	Hashes map[string]string `json:"hashes"`
+	mu   sync.Mutex
+	// saveMuは保存処理を直列化し、古い内容が新しい内容を上書きしないようにします。
+	saveMu sync.Mutex
+
+	Version int `json:"version"`
+	// キー: CacheKeyから作成した文字列
+	Entries map[string]CacheEntry `json:"entries"`
+	// LegacyHashesはバージョン1のキャッシュ(キー: ソースのパス, 値: MD5ハッシュ)です。
+	// 出力先が存在する場合に限り、新しい形式のエントリへ移行するために使用します。
+	// 移行したソースや翻訳し直したソースは削除し、全て移行した後はキャッシュファイルに出力しません。
+	LegacyHashes map[string]string `json:"legacy_hashes,omitempty"`
 }
 
 // NewCacheは新しいCacheインスタンスを作成し、既存のキャッシュファイルを読み込みます。
 func NewCache(projectRoot string) (*Cache, error) {
 	cachePath := filepath.Join(projectRoot, cacheFileName)
 	c := &Cache{
-		path:   cachePath,
-		Hashes: make(map[string]string),
+		path:    cachePath,
+		Version: cacheSchemaVersion,
+		Entries: make(map[string]CacheEntry),
 	}
 	if err := c.Load(); err != nil {
 		// ファイルが存在しない場合はエラーとしない
@@ -35,36 +75,145 @@ func NewCache(projectRoot string) (*Cache, error) {
 }
 
 // Loadはキャッシュファイルをディスクから読み込みます。
+// 古い形式のキャッシュファイルは、新しい形式に移行して読み込みます。
 func (c *Cache) Load() error {
 	data, err := os.ReadFile(c.path)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(data, &c)
+
+	var file struct {
+		Version      int                   `json:"version"`
+		Entries      map[string]CacheEntry `json:"entries"`
+		LegacyHashes map[string]string     `json:"legacy_hashes"`
+		// バージョン1の形式
+		Hashes map[string]string `json:"hashes"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse cache file %s: %w", c.path, err)
+	}
+	if file.Version > cacheSchemaVersion {
+		return fmt.Errorf("cache file %s has unsupported version %d (expected %d or lower)", c.path, file.Version, cacheSchemaVersion)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Version = cacheSchemaVersion
+	c.Entries = make(map[string]CacheEntry, len(file.Entries))
+	for k, v := range file.Entries {
+		c.Entries[k] = v
+	}
+	c.LegacyHashes = file.LegacyHashes
+	if file.Version < 2 && len(file.Hashes) > 0 {
+		c.LegacyHashes = file.Hashes
+	}
+	// 削除されたソースは移行する必要がない
+	for source := range c.LegacyHashes {
+		if _, err := os.Stat(source); err != nil {
+			delete(c.LegacyHashes, source)
+		}
+	}
+	return nil
 }
 
-// Saveは現在のキャッシュの状態をディスクに保存します。
+// Saveは現在のキャッシュの状態をディスクにアトミックに保存します。
 func (c *Cache) Save() error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
 	data, err := json.MarshalIndent(c, "", "  ")
+	c.mu.Unlock()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(c.path, data, 0644)
+	return writeFileAtomic(c.path, data, 0644)
 }
 
-// IsChangedはファイルのハッシュがキャッシュ内のものと異なるかを確認します。
+// IsChangedはファイルのハッシュや翻訳設定がキャッシュ内のものと異なるかを確認します。
 // キャッシュに存在しない場合は変更ありとみなします。
-func (c *Cache) IsChanged(filePath, currentHash string) bool {
-	cachedHash, ok := c.Hashes[filePath]
+func (c *Cache) IsChanged(key CacheKey, currentHash, fingerprint string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.Entries[key.String()]
 	if !ok {
-		return true // キャッシュにない場合は変更あり
+		return !c.migrateLegacy(key, currentHash, fingerprint)
+	}
+	return entry.Hash != currentHash || entry.Fingerprint != fingerprint
+}
+
+// migrateLegacyはバージョン1のキャッシュにソースのハッシュが一致するエントリがあり、
+// 出力先が存在する場合に、新しい形式のエントリとして登録します。
+// バージョン1は翻訳先の言語を区別していなかったため、出力先が存在しない場合は移行しません。
+func (c *Cache) migrateLegacy(key CacheKey, currentHash, fingerprint string) bool {
+	legacyHash, ok := c.LegacyHashes[key.Source]
+	if !ok || legacyHash != currentHash {
+		return false
+	}
+	if _, err := os.Stat(key.Destination); err != nil {
+		return false
+	}
+	c.Entries[key.String()] = newCacheEntry(key, currentHash, fingerprint)
+	delete(c.LegacyHashes, key.Source)
+	return true
+}
+
+// Updateはキャッシュ内のファイルのハッシュと翻訳設定、出力先に書き込んだ内容のハッシュを更新します。
+func (c *Cache) Update(key CacheKey, newHash, fingerprint, outputHash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := newCacheEntry(key, newHash, fingerprint)
+	entry.OutputHash = outputHash
+	c.Entries[key.String()] = entry
+	// 翻訳し直したソースは、バージョン1のキャッシュから移行する必要がない
+	delete(c.LegacyHashes, key.Source)
+}
+
+// Removeはキャッシュからエントリを削除します。
+func (c *Cache) Remove(key CacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.Entries, key.String())
+}
+
+// entriesはキャッシュエントリの一覧をソースと出力先のパスの順に返します。
+func (c *Cache) entries() []CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]CacheEntry, 0, len(c.Entries))
+	for _, entry := range c.Entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].Source != entries[b].Source {
+			return entries[a].Source < entries[b].Source
+		}
+		if entries[a].Destination != entries[b].Destination {
+			return entries[a].Destination < entries[b].Destination
+		}
+		return entries[a].TargetLang < entries[b].TargetLang
+	})
+	return entries
+}
+
+// Stringはキャッシュファイル内で使用するキーの文字列を返します。
+func (k CacheKey) String() string {
+	return fmt.Sprintf("%s -> %s [%s]", k.Source, k.Destination, k.TargetLang)
+}
+
+func newCacheEntry(key CacheKey, hash, fingerprint string) CacheEntry {
+	return CacheEntry{
+		Source:      key.Source,
+		Destination: key.Destination,
+		TargetLang:  key.TargetLang,
+		Hash:        hash,
+		Fingerprint: fingerprint,
 	}
-	return cachedHash != currentHash
 }
 
-// Updateはキャッシュ内のファイルのハッシュを更新します。
-func (c *Cache) Update(filePath, newHash string) {
-	c.Hashes[filePath] = newHash
+// md5Hexはdataのハッシュを、CalculateMD5と同じ形式で返します。
+func md5Hex(data []byte) string {
+	return fmt.Sprintf("%x", md5.Sum(data))
 }
 
 // CalculateMD5はファイルのMD5ハッシュを計算します。
@@ -0,0 +1,303 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 複数のワーカーから同時にUpdate、IsChanged、Saveを呼び出しても、データ競合が起きず、すべての更新が保存される
+// データ競合の検出には go test -race で実行する
+func TestCacheConcurrentAccess(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 16
+	const files = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < files; i++ {
+				key := CacheKey{Source: fmt.Sprintf("docs/%d/%d.md", w, i), Destination: fmt.Sprintf("out/%d/%d.md", w, i), TargetLang: "EN"}
+				hash := fmt.Sprintf("hash-%d-%d", w, i)
+				if !cache.IsChanged(key, hash, "fp") {
+					errs <- fmt.Errorf("%v: new entry reported as unchanged", key)
+					return
+				}
+				cache.Update(key, hash, "fp", "")
+				if cache.IsChanged(key, hash, "fp") {
+					errs <- fmt.Errorf("%v: updated entry reported as changed", key)
+					return
+				}
+				// 全ワーカーで共有するエントリも更新する
+				shared := CacheKey{Source: "shared.md", Destination: "out/shared.md", TargetLang: "EN"}
+				cache.Update(shared, hash, "fp", "")
+				cache.IsChanged(shared, hash, "fp")
+				if i%10 == 0 {
+					if err := cache.Save(); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(loaded.Entries), workers*files+1; got != want {
+		t.Fatalf("saved %d entries, want %d", got, want)
+	}
+	for w := 0; w < workers; w++ {
+		for i := 0; i < files; i++ {
+			key := CacheKey{Source: fmt.Sprintf("docs/%d/%d.md", w, i), Destination: fmt.Sprintf("out/%d/%d.md", w, i), TargetLang: "EN"}
+			if loaded.IsChanged(key, fmt.Sprintf("hash-%d-%d", w, i), "fp") {
+				t.Errorf("%v: entry was not saved", key)
+			}
+		}
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "."+cacheFileName+".tmp-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) > 0 {
+		t.Errorf("temporary files were left behind: %v", matches)
+	}
+}
+
+// saveHelperEnvは、TestCacheSaveInterruptedから起動されたプロセスでキャッシュを保存し続けるための環境変数です。
+const saveHelperEnv = "TRANSLATE_MARKDOWN_CACHE_SAVE_HELPER"
+
+// cacheTestEntriesはテスト用のキャッシュエントリをn件登録します。
+func cacheTestEntries(cache *Cache, n int, hash string) {
+	for i := 0; i < n; i++ {
+		key := CacheKey{Source: fmt.Sprintf("docs/%05d.md", i), Destination: fmt.Sprintf("out/%05d.md", i), TargetLang: "EN"}
+		cache.Update(key, hash, "fp", "")
+	}
+}
+
+const interruptedEntries = 20000
+
+// TestCacheSaveHelperはTestCacheSaveInterruptedから別プロセスとして起動され、強制終了されるまでキャッシュを保存し続けます。
+func TestCacheSaveHelper(t *testing.T) {
+	dir := os.Getenv(saveHelperEnv)
+	if dir == "" {
+		t.Skip("helper process for TestCacheSaveInterrupted")
+	}
+	cache, err := NewCache(dir)
+	if err != nil {
+		os.Exit(2)
+	}
+	cacheTestEntries(cache, interruptedEntries, "new")
+	// 保存を始めたことを親プロセスに知らせる
+	fmt.Println("saving")
+	for {
+		if err := cache.Save(); err != nil {
+			os.Exit(2)
+		}
+	}
+}
+
+// 保存中のプロセスが強制終了されても、キャッシュファイルは以前の内容か新しい内容のいずれかで、途中までの内容にはならない
+func TestCacheSaveInterrupted(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping subprocess test in short mode")
+	}
+	dir := t.TempDir()
+	cache, err := NewCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheTestEntries(cache, interruptedEntries, "old")
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 0; attempt < 5; attempt++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCacheSaveHelper$")
+		cmd.Env = append(os.Environ(), saveHelperEnv+"="+dir)
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		started := false
+		for scanner := bufio.NewScanner(stdout); scanner.Scan(); {
+			if scanner.Text() == "saving" {
+				started = true
+				break
+			}
+		}
+		if !started {
+			t.Fatal("helper process did not start saving")
+		}
+		time.Sleep(time.Duration(attempt*7) * time.Millisecond)
+		if err := cmd.Process.Kill(); err != nil {
+			t.Fatal(err)
+		}
+		_ = cmd.Wait()
+
+		loaded, err := NewCache(dir)
+		if err != nil {
+			t.Fatalf("attempt %d: cache file is broken after interruption: %v", attempt, err)
+		}
+		if len(loaded.Entries) != interruptedEntries {
+			t.Fatalf("attempt %d: loaded %d entries, want %d", attempt, len(loaded.Entries), interruptedEntries)
+		}
+		hash := ""
+		for _, entry := range loaded.Entries {
+			if hash == "" {
+				hash = entry.Hash
+			}
+			if entry.Hash != hash || (hash != "old" && hash != "new") {
+				t.Fatalf("attempt %d: cache file mixes old and new contents", attempt)
+			}
+		}
+	}
+}
+
+// 中断された保存の一時ファイルが残っていても、以前のキャッシュファイルを読み込み、次の保存も成功する
+func TestCacheLeftoverTempFile(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := CacheKey{Source: "a.md", Destination: "out/a.md", TargetLang: "EN"}
+	cache.Update(key, "old", "fp", "")
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tmp := filepath.Join(dir, "."+cacheFileName+".tmp-interrupted")
+	if err := os.WriteFile(tmp, []byte(`{"version": 2, "entries": {`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.IsChanged(key, "old", "fp") {
+		t.Fatal("previous cache entry was lost")
+	}
+	loaded.Update(key, "new", "fp", "")
+	if err := loaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.IsChanged(key, "new", "fp") {
+		t.Error("cache entry was not updated")
+	}
+}
+
+// バージョン1のキャッシュファイルは、出力先が存在するソースのみ新しい形式のエントリに移行し、
+// 移行や翻訳を終えたソースと削除されたソースはLegacyHashesから除く
+func TestCacheMigrateLegacy(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"docs/a.md": "A.\n",
+		"out/a.md":  "[EN] A.\n",
+		"docs/b.md": "B.\n",
+	})
+	path := func(name string) string { return filepath.Join(dir, filepath.FromSlash(name)) }
+	hash := func(name string) string {
+		h, err := CalculateMD5(path(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	legacy, err := json.Marshal(map[string]any{"hashes": map[string]string{
+		path("docs/a.md"):       hash("docs/a.md"),
+		path("docs/b.md"):       hash("docs/b.md"),
+		path("docs/removed.md"): "0123",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path(cacheFileName), legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := NewCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(cache.LegacyHashes); got != 2 {
+		t.Errorf("loaded %d legacy hashes, want 2 (removed source is dropped)", got)
+	}
+
+	a := CacheKey{Source: path("docs/a.md"), Destination: path("out/a.md"), TargetLang: "EN"}
+	if cache.IsChanged(a, hash("docs/a.md"), "fp") {
+		t.Error("translated file with a legacy hash was reported as changed")
+	}
+	// 出力先が存在しない場合は、他の言語の翻訳の可能性があるため移行しない
+	b := CacheKey{Source: path("docs/b.md"), Destination: path("out/b.md"), TargetLang: "EN"}
+	if !cache.IsChanged(b, hash("docs/b.md"), "fp") {
+		t.Error("file without an output was reported as unchanged")
+	}
+	if _, ok := cache.LegacyHashes[b.Source]; !ok {
+		t.Error("legacy hash of a file that was not migrated was dropped")
+	}
+	cache.Update(b, hash("docs/b.md"), "fp", "")
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path(cacheFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		Version      int                   `json:"version"`
+		Entries      map[string]CacheEntry `json:"entries"`
+		LegacyHashes map[string]string     `json:"legacy_hashes"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Version != cacheSchemaVersion {
+		t.Errorf("version = %d, want %d", saved.Version, cacheSchemaVersion)
+	}
+	for key, want := range map[CacheKey]string{a: hash("docs/a.md"), b: hash("docs/b.md")} {
+		entry, ok := saved.Entries[key.String()]
+		if !ok {
+			t.Errorf("%v was not saved", key)
+			continue
+		}
+		if entry.Hash != want || entry.Fingerprint != "fp" || entry.TargetLang != "EN" {
+			t.Errorf("%v = %+v", key, entry)
+		}
+	}
+	if len(saved.LegacyHashes) != 0 {
+		t.Errorf("legacy hashes were saved after migration: %v", saved.LegacyHashes)
+	}
+}
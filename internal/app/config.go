@@ -8,18 +8,70 @@ import (
 
 // Configは設定ファイル(config.toml)の構造を表します。
 type Config struct {
-	TargetLang string `toml:"target_lang"`
-	SourceLang string `toml:"source_lang"`
-	Jobs       []Job  `toml:"jobs"`
+	APIURL              string     `toml:"api_url"`
+	BatchConcurrency    int        `toml:"batch_concurrency"`
+	RequestsPerSec      float64    `toml:"requests_per_second"`
+	CharsPerMinute      int        `toml:"chars_per_minute"`
+	MaxRetries          *int       `toml:"max_retries"`
+	TargetLang          string     `toml:"target_lang"`
+	TargetLangs         []string   `toml:"target_langs"`
+	SourceLang          string     `toml:"source_lang"`
+	Context             string     `toml:"context"`
+	Formality           string     `toml:"formality"`
+	TranslationUnit     string     `toml:"translation_unit"`
+	SkipNodes           []string   `toml:"skip_nodes"`
+	Protect             []string   `toml:"protect"`
+	ProtectPatterns     []string   `toml:"protect_patterns"`
+	PricePerMillion     float64    `toml:"price_per_million_chars"`
+	CharBudget          int64      `toml:"char_budget"`
+	SkipUnchangedOutput bool       `toml:"skip_unchanged_output"`
+	Glossaries          []Glossary `toml:"glossaries"`
+	Jobs                []Job      `toml:"jobs"`
 }
 
 // Jobは個々の翻訳タスクを表します。
 type Job struct {
-	Source      string   `toml:"source"`
-	Destination string   `toml:"destination"`
-	TargetLang  string   `toml:"target_lang"`
-	SourceLang  string   `toml:"source_lang"`
-	Exclude     []string `toml:"exclude"`
+	Source string `toml:"source"`
+	// Destinationは出力先のパスです。{lang}、{dir}、{name}などのプレースホルダを含めることができます。
+	Destination     string   `toml:"destination"`
+	TargetLang      string   `toml:"target_lang"`
+	TargetLangs     []string `toml:"target_langs"`
+	SourceLang      string   `toml:"source_lang"`
+	Context         string   `toml:"context"`
+	Formality       string   `toml:"formality"`
+	TranslationUnit string   `toml:"translation_unit"`
+	SkipNodes       []string `toml:"skip_nodes"`
+	// Includeはディレクトリのジョブで翻訳するファイルのパターンです(例: ["guides/**"])。
+	// 空の場合は全てのファイルを翻訳します。パターンはsourceからの相対パスと照合します。
+	Include []string `toml:"include"`
+	// Excludeは翻訳しないファイルのパターンです。includeと同様にsourceからの相対パスと照合します。
+	Exclude []string `toml:"exclude"`
+	// Extensionsはディレクトリのジョブで翻訳するファイルの拡張子です。省略した場合は.mdです。
+	Extensions []string `toml:"extensions"`
+	// Protectは翻訳から保護するテンプレート構文のプリセット("hugo"、"jekyll"、"liquid"、"jinja"、"docusaurus")です。
+	// グローバル設定に追加されます。
+	Protect []string `toml:"protect"`
+	// ProtectPatternsは翻訳から保護する文字列の正規表現です。グローバル設定に追加されます。
+	ProtectPatterns []string `toml:"protect_patterns"`
+	// FrontmatterKeysはYAMLのFrontmatterのうち、値を翻訳するキーです(例: ["title", "description"])。
+	FrontmatterKeys []string `toml:"frontmatter_keys"`
+	// MDXがtrueの場合、拡張子が.mdのファイルもMDXとして解析します。拡張子が.mdxのファイルは常にMDXとして解析します。
+	MDX bool `toml:"mdx"`
+	// MDXPropsはMDXのJSXの属性のうち、文字列の値を翻訳する属性名です。省略した場合はtitle、label、alt、descriptionです。
+	MDXProps []string `toml:"mdx_props"`
+	// Orphansはディレクトリのジョブで、ソースファイルが削除された翻訳済みファイルの扱いです。
+	// "list"(一覧に表示)、"delete"(削除)、"trash"(ゴミ箱へ移動)のいずれかで、空の場合は確認しません。
+	Orphans string `toml:"orphans"`
+	// OrphansTrashはorphansが"trash"の場合の移動先です。空の場合はプロジェクトルートの.translation_trashです。
+	OrphansTrash string `toml:"orphans_trash"`
+}
+
+// Glossaryは用語集ファイルと、その言語ペアを表します。
+type Glossary struct {
+	SourceLang string `toml:"source_lang"`
+	TargetLang string `toml:"target_lang"`
+	// Fileは用語集ファイルのパスです。拡張子(.tsvまたは.csv)で形式を判断します。
+	File string `toml:"file"`
 }
 
 // LoadConfigは指定されたパスから設定ファイルを読み込み、解析します。
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// destinationに指定できるプレースホルダです。
+const (
+	// placeholderLangは翻訳先の言語コードを小文字にしたもの(例: "en-us")に置き換えられます。
+	placeholderLang = "{lang}"
+	// placeholderLangUpperは翻訳先の言語コードを大文字にしたもの(例: "EN-US")に置き換えられます。
+	placeholderLangUpper = "{LANG}"
+	// placeholderDirはソースファイルのあるディレクトリに置き換えられます。
+	placeholderDir = "{dir}"
+	// placeholderNameはソースファイルの拡張子を除いたファイル名に置き換えられます。
+	placeholderName = "{name}"
+	// placeholderExtはソースファイルのドットを除いた拡張子に置き換えられます。
+	placeholderExt = "{ext}"
+)
+
+// targetLangsはジョブの翻訳先の言語の一覧を返します。
+// ジョブの設定が指定されている場合はグローバル設定より優先され、target_langsはtarget_langより優先されます。
+func targetLangs(job Job, cfg *Config) ([]string, error) {
+	var langs []string
+	switch {
+	case len(job.TargetLangs) > 0:
+		langs = job.TargetLangs
+	case job.TargetLang != "":
+		langs = []string{job.TargetLang}
+	case len(cfg.TargetLangs) > 0:
+		langs = cfg.TargetLangs
+	case cfg.TargetLang != "":
+		langs = []string{cfg.TargetLang}
+	default:
+		return nil, fmt.Errorf("target_lang is not specified for job or globally")
+	}
+
+	seen := make(map[string]bool, len(langs))
+	var result []string
+	for _, lang := range langs {
+		lang = strings.ToUpper(strings.TrimSpace(lang))
+		if lang == "" {
+			return nil, fmt.Errorf("target_langs contains an empty language code")
+		}
+		if !seen[lang] {
+			seen[lang] = true
+			result = append(result, lang)
+		}
+	}
+	return result, nil
+}
+
+// validateDestinationは翻訳先の言語ごとに異なる出力先になるかを確認します。
+func validateDestination(destination string, langs []string) error {
+	if destination == "" {
+		return fmt.Errorf("destination is not specified")
+	}
+	if len(langs) > 1 && !strings.Contains(destination, placeholderLang) && !strings.Contains(destination, placeholderLangUpper) {
+		return fmt.Errorf("destination %q must contain %s when multiple target languages are specified", destination, placeholderLang)
+	}
+	return nil
+}
+
+// isFileTemplateはdestinationがソースファイルごとのパスを表すテンプレートかを返します。
+// {dir}、{name}、{ext}を含む場合は、ディレクトリのジョブでもソースファイルごとにテンプレートを展開します。
+func isFileTemplate(destination string) bool {
+	return strings.Contains(destination, placeholderDir) ||
+		strings.Contains(destination, placeholderName) ||
+		strings.Contains(destination, placeholderExt)
+}
+
+// destinationPathはソースファイルと翻訳先の言語に対応する出力先のパスを返します。
+// sourcePathがジョブのsourceそのものでない場合は、ディレクトリのジョブ内のファイルとして扱います。
+func destinationPath(job Job, lang, sourcePath string) (string, error) {
+	replacer := strings.NewReplacer(
+		placeholderLang, strings.ToLower(lang),
+		placeholderLangUpper, strings.ToUpper(lang),
+	)
+
+	if isFileTemplate(job.Destination) {
+		base := filepath.Base(sourcePath)
+		ext := filepath.Ext(base)
+		fileReplacer := strings.NewReplacer(
+			placeholderDir, filepath.Dir(sourcePath),
+			placeholderName, strings.TrimSuffix(base, ext),
+			placeholderExt, strings.TrimPrefix(ext, "."),
+		)
+		return filepath.Clean(fileReplacer.Replace(replacer.Replace(job.Destination))), nil
+	}
+
+	root := replacer.Replace(job.Destination)
+	if filepath.Clean(sourcePath) == filepath.Clean(job.Source) {
+		return root, nil
+	}
+	relPath, err := filepath.Rel(job.Source, sourcePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, relPath), nil
+}
+
+// filePlaceholderPatternはdestinationのテンプレートのうち、ソースファイルごとに展開されるプレースホルダに一致します。
+var filePlaceholderPattern = regexp.MustCompile(`\{dir\}/?|\{name\}|\{ext\}`)
+
+// destinationPatternはディレクトリのジョブで、langへの翻訳の出力先になり得るパス("/"区切り)に一致する正規表現を返します。
+// ソースが削除された後も、以前の実行で出力された翻訳済みファイルをソースとして扱わないために使用します。
+// 出力先のディレクトリがソースのディレクトリの外にあり、走査するファイルが出力先になり得ない場合はnilを返します。
+func destinationPattern(job Job, lang string) *regexp.Regexp {
+	replacer := strings.NewReplacer(
+		placeholderLang, strings.ToLower(lang),
+		placeholderLangUpper, strings.ToUpper(lang),
+	)
+	destination := replacer.Replace(job.Destination)
+	if !isFileTemplate(job.Destination) {
+		if !withinDir(job.Source, destination) {
+			return nil
+		}
+		// 出力先のディレクトリの配下の全てのファイル
+		return regexp.MustCompile(`^` + regexp.QuoteMeta(filepath.ToSlash(filepath.Clean(destination))) + `(?:/.*)?$`)
+	}
+	destination = filepath.ToSlash(destination)
+
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, m := range filePlaceholderPattern.FindAllStringIndex(destination, -1) {
+		b.WriteString(regexp.QuoteMeta(destination[last:m[0]]))
+		switch destination[m[0]:m[1]] {
+		case placeholderDir + "/":
+			// ソースのディレクトリが"."の場合、出力先のパスにディレクトリは含まれない
+			b.WriteString(`(?:.*/)?`)
+		case placeholderDir:
+			b.WriteString(`.*`)
+		default:
+			b.WriteString(`[^/]*`)
+		}
+		last = m[1]
+	}
+	b.WriteString(regexp.QuoteMeta(destination[last:]))
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTargetLangs(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     Job
+		cfg     Config
+		want    []string
+		wantErr string
+	}{
+		{name: "job target_langs", job: Job{TargetLangs: []string{"en-us", "DE"}, TargetLang: "FR"}, cfg: Config{TargetLang: "ZH"}, want: []string{"EN-US", "DE"}},
+		{name: "job target_lang", job: Job{TargetLang: "fr"}, cfg: Config{TargetLangs: []string{"ZH"}}, want: []string{"FR"}},
+		{name: "global target_langs", cfg: Config{TargetLangs: []string{"DE", "ZH"}, TargetLang: "FR"}, want: []string{"DE", "ZH"}},
+		{name: "global target_lang", cfg: Config{TargetLang: "EN-GB"}, want: []string{"EN-GB"}},
+		{name: "duplicates and spaces", job: Job{TargetLangs: []string{" de", "DE", "en-us", "EN-US "}}, want: []string{"DE", "EN-US"}},
+		{name: "not specified", wantErr: "target_lang is not specified"},
+		{name: "empty language", job: Job{TargetLangs: []string{"DE", " "}}, wantErr: "empty language code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := targetLangs(tt.job, &tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("targetLangs() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("targetLangs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDestination(t *testing.T) {
+	tests := []struct {
+		destination string
+		langs       []string
+		wantErr     bool
+	}{
+		{destination: "docs/en", langs: []string{"EN"}},
+		{destination: "docs/{lang}", langs: []string{"EN", "DE"}},
+		{destination: "docs/{LANG}", langs: []string{"EN", "DE"}},
+		{destination: "{dir}/{name}.{lang}.md", langs: []string{"EN", "DE"}},
+		{destination: "", langs: []string{"EN"}, wantErr: true},
+		{destination: "docs/en", langs: []string{"EN", "DE"}, wantErr: true},
+		{destination: "{dir}/{name}.en.md", langs: []string{"EN", "DE"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := validateDestination(tt.destination, tt.langs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateDestination(%q, %q) = %v, want error %v", tt.destination, tt.langs, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDestinationPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		destination string
+		lang        string
+		path        string
+		want        string
+	}{
+		{name: "single file", source: "docs/a.md", destination: "out/a.md", lang: "EN", path: "docs/a.md", want: "out/a.md"},
+		{name: "single file with lang", source: "docs/a.md", destination: "out/{lang}/a.md", lang: "EN-US", path: "docs/a.md", want: "out/en-us/a.md"},
+		{name: "directory", source: "docs/jp", destination: "docs/en", lang: "EN", path: "docs/jp/guide/a.md", want: "docs/en/guide/a.md"},
+		{name: "directory with lang", source: "docs/jp", destination: "docs/{lang}/", lang: "EN-US", path: "docs/jp/a.md", want: "docs/en-us/a.md"},
+		{name: "directory with upper lang", source: "docs/jp", destination: "i18n/{LANG}", lang: "en-us", path: "docs/jp/a.md", want: "i18n/EN-US/a.md"},
+		{name: "file template", source: "docs", destination: "{dir}/{name}.{lang}.md", lang: "DE", path: "docs/guide/a.md", want: "docs/guide/a.de.md"},
+		{name: "file template keeps ext", source: "docs", destination: "out/{lang}/{name}.{ext}", lang: "DE", path: "docs/a.mdx", want: "out/de/a.mdx"},
+		{name: "file template for single file", source: "README.md", destination: "{dir}/{name}.{lang}.{ext}", lang: "EN", path: "README.md", want: "README.en.md"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := Job{Source: filepath.FromSlash(tt.source), Destination: filepath.FromSlash(tt.destination)}
+			got, err := destinationPath(job, tt.lang, filepath.FromSlash(tt.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filepath.ToSlash(got) != tt.want {
+				t.Errorf("destinationPath() = %q, want %q", filepath.ToSlash(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestDestinationPattern(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination string
+		lang        string
+		match       []string
+		noMatch     []string
+	}{
+		{
+			name:        "file template",
+			destination: "{dir}/{name}.{lang}.md",
+			lang:        "EN-US",
+			match:       []string{"docs/b.en-us.md", "docs/guide/c.en-us.md"},
+			noMatch:     []string{"docs/b.md", "docs/b.de.md", "docs/b.en-us.mdx"},
+		},
+		{
+			name:        "file template with upper lang and ext",
+			destination: "{dir}/{LANG}/{name}.{ext}",
+			lang:        "de",
+			match:       []string{"docs/DE/a.md", "docs/guide/DE/a.mdx"},
+			noMatch:     []string{"docs/de/a.md", "docs/a.md"},
+		},
+		{
+			name:        "directory inside the source",
+			destination: "docs/{lang}",
+			lang:        "DE",
+			match:       []string{"docs/de/a.md", "docs/de/guide/b.md"},
+			noMatch:     []string{"docs/a.md", "docs/deep/a.md"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern := destinationPattern(Job{Source: "docs", Destination: tt.destination}, tt.lang)
+			if pattern == nil {
+				t.Fatal("destinationPattern() = nil")
+			}
+			for _, path := range tt.match {
+				if !pattern.MatchString(path) {
+					t.Errorf("%q does not match %s", path, pattern)
+				}
+			}
+			for _, path := range tt.noMatch {
+				if pattern.MatchString(path) {
+					t.Errorf("%q matches %s", path, pattern)
+				}
+			}
+		})
+	}
+
+	// 出力先のディレクトリがソースの外にある場合、走査するファイルは出力先にならない
+	if pattern := destinationPattern(Job{Source: "docs/jp", Destination: "docs/{lang}"}, "EN"); pattern != nil {
+		t.Errorf("destinationPattern() = %s, want nil", pattern)
+	}
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+
+	"github.com/ariela/translate-markdown/internal/deepl"
+)
+
+// レポートに記録するエラーの種類です。
+const (
+	CategoryConfig     = "config"
+	CategoryAuth       = "auth"
+	CategoryQuota      = "quota"
+	CategoryRateLimit  = "rate_limit"
+	CategoryServer     = "server"
+	CategoryAPI        = "api"
+	CategoryNetwork    = "network"
+	CategoryParse      = "parse"
+	CategoryValidation = "validation"
+	CategoryIO         = "io"
+	CategoryCanceled   = "canceled"
+	CategoryOther      = "other"
+)
+
+// categoryErrorはエラーの種類を付加したエラーです。
+type categoryError struct {
+	category string
+	err      error
+}
+
+func (e *categoryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *categoryError) Unwrap() error {
+	return e.err
+}
+
+// withCategoryはerrにエラーの種類を付加します。errがnilの場合はnilを返します。
+func withCategory(category string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &categoryError{category: category, err: err}
+}
+
+// ErrorCategoryはエラーの種類を返します。errがnilの場合は空文字列を返します。
+// withCategoryで付加された種類を優先し、それ以外はDeepL APIのステータスコードやエラーの型から判断します。
+func ErrorCategory(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var catErr *categoryError
+	if errors.As(err, &catErr) {
+		return catErr.category
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return CategoryCanceled
+	}
+	if errors.Is(err, ErrQuotaExceeded) {
+		return CategoryQuota
+	}
+
+	var apiErr *deepl.APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+			return CategoryAuth
+		case apiErr.StatusCode == 456:
+			return CategoryQuota
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return CategoryRateLimit
+		case apiErr.StatusCode >= 500:
+			return CategoryServer
+		default:
+			return CategoryAPI
+		}
+	}
+	if errors.Is(err, deepl.ErrCountMismatch) {
+		return CategoryValidation
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return CategoryNetwork
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return CategoryIO
+	}
+	return CategoryOther
+}
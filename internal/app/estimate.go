@@ -0,0 +1,157 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// DefaultPricePerMillionはprice_per_million_charsを省略した場合の100万文字あたりの料金です。
+// DeepL API Proの従量課金(USD)に合わせています。
+const DefaultPricePerMillion = 25.0
+
+// FileEstimateはドライランでのファイル1件の見積もりです。
+type FileEstimate struct {
+	Source      string
+	Destination string
+	TargetLang  string
+	// Charsは翻訳メモリに見つからず、APIに送信される文字数です。
+	Chars          int
+	ReusedSegments int
+	// SkipReasonはスキップされる理由(SkipUnchanged、SkipExcluded)です。翻訳される場合は空になります。
+	SkipReason string
+}
+
+// JobEstimateはドライランでのジョブ1件の見積もりです。
+type JobEstimate struct {
+	Source      string
+	Destination string
+	Files       []FileEstimate
+	// Orphansはソースファイルが削除され、orphansの設定に従って処理される翻訳済みファイルです。
+	Orphans []Orphan
+}
+
+// Charsはジョブ全体でAPIに送信される文字数を返します。
+func (j JobEstimate) Chars() int {
+	total := 0
+	for _, f := range j.Files {
+		total += f.Chars
+	}
+	return total
+}
+
+// sortedFilesはワーカーの処理順に依存しないよう、パスの順に並べたファイルの見積もりを返します。
+func (j JobEstimate) sortedFiles() []FileEstimate {
+	files := append([]FileEstimate(nil), j.Files...)
+	sort.SliceStable(files, func(a, b int) bool {
+		if files[a].Source != files[b].Source {
+			return files[a].Source < files[b].Source
+		}
+		return files[a].Destination < files[b].Destination
+	})
+	return files
+}
+
+// Estimateはドライランの結果をジョブごとに集計します。
+type Estimate struct {
+	mu   sync.Mutex
+	Jobs []JobEstimate
+}
+
+// NewEstimateは新しいEstimateインスタンスを作成します。
+func NewEstimate() *Estimate {
+	return &Estimate{}
+}
+
+// beginJobは以降に記録するファイルの見積もりを、指定されたジョブのものとして集計します。
+// ジョブは1つずつ順に処理されるため、ファイルは最後に開始したジョブに追加されます。
+func (e *Estimate) beginJob(job Job) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Jobs = append(e.Jobs, JobEstimate{Source: job.Source, Destination: job.Destination})
+}
+
+// addFileは現在のジョブにファイルの見積もりを追加します。
+func (e *Estimate) addFile(f FileEstimate) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.Jobs) == 0 {
+		e.Jobs = append(e.Jobs, JobEstimate{})
+	}
+	job := &e.Jobs[len(e.Jobs)-1]
+	job.Files = append(job.Files, f)
+}
+
+// addOrphanは現在のジョブにソースファイルが削除された翻訳済みファイルを追加します。
+func (e *Estimate) addOrphan(o Orphan) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.Jobs) == 0 {
+		e.Jobs = append(e.Jobs, JobEstimate{})
+	}
+	job := &e.Jobs[len(e.Jobs)-1]
+	job.Orphans = append(job.Orphans, o)
+}
+
+// TotalCharsは全てのジョブでAPIに送信される文字数を返します。
+func (e *Estimate) TotalChars() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	total := 0
+	for _, j := range e.Jobs {
+		total += j.Chars()
+	}
+	return total
+}
+
+// orderedFilesは翻訳されるファイルの見積もりを、ジョブの順、ファイルのパスの順に返します。
+// スキップされるファイルは含みません。
+func (e *Estimate) orderedFiles() []FileEstimate {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var files []FileEstimate
+	for _, j := range e.Jobs {
+		for _, f := range j.sortedFiles() {
+			if f.SkipReason == "" {
+				files = append(files, f)
+			}
+		}
+	}
+	return files
+}
+
+// Printは見積もりをwに出力します。
+// pricePerMillionが0以下の場合はDefaultPricePerMillionで費用を計算します。
+func (e *Estimate) Print(w io.Writer, pricePerMillion float64) {
+	if pricePerMillion <= 0 {
+		pricePerMillion = DefaultPricePerMillion
+	}
+	total := e.TotalChars()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	fmt.Fprintln(w, "\n--- Dry Run Estimate ---")
+	for _, j := range e.Jobs {
+		fmt.Fprintf(w, "Job: %s -> %s\n", j.Source, j.Destination)
+		for _, f := range j.sortedFiles() {
+			switch {
+			case f.SkipReason == SkipExcluded:
+				fmt.Fprintf(w, "  ⏩ skipped (%s) %s\n", f.SkipReason, f.Source)
+			case f.SkipReason != "":
+				fmt.Fprintf(w, "  ⏩ skipped (%s) %s -> %s [%s]\n", f.SkipReason, f.Source, f.Destination, f.TargetLang)
+			default:
+				fmt.Fprintf(w, "  🔤 %8d chars %s -> %s [%s]\n", f.Chars, f.Source, f.Destination, f.TargetLang)
+			}
+		}
+		for _, o := range j.Orphans {
+			fmt.Fprintf(w, "  🧹 orphan (%s) %s [%s] (source %s was removed)\n", o.Action, o.Destination, o.TargetLang, o.Source)
+		}
+		fmt.Fprintf(w, "  Job total: %d chars\n", j.Chars())
+	}
+	fmt.Fprintln(w, "------------------------")
+	fmt.Fprintf(w, "🔤 Characters:     %d\n", total)
+	fmt.Fprintf(w, "💰 Estimated cost: %.2f (%.2f per 1M characters)\n", float64(total)*pricePerMillion/1_000_000, pricePerMillion)
+	fmt.Fprintln(w, "------------------------")
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// defaultExtensionsはextensionsを省略した場合に翻訳するファイルの拡張子です。
+var defaultExtensions = []string{".md"}
+
+// fileFilterはディレクトリのジョブで翻訳するファイルを、拡張子とinclude、excludeのパターンで選択します。
+// パターンはジョブのsourceからの相対パス("/"区切り)と照合します。
+type fileFilter struct {
+	root       string
+	extensions []string
+	include    []string
+	exclude    []string
+}
+
+// newFileFilterはジョブの設定からfileFilterを作成します。パターンと拡張子が正しいかもここで確認します。
+func newFileFilter(job Job) (fileFilter, error) {
+	f := fileFilter{root: job.Source, include: job.Include, exclude: job.Exclude}
+	for _, pattern := range job.Include {
+		if !doublestar.ValidatePattern(pattern) {
+			return f, fmt.Errorf("invalid include pattern %q", pattern)
+		}
+	}
+	for _, pattern := range job.Exclude {
+		if !doublestar.ValidatePattern(pattern) {
+			return f, fmt.Errorf("invalid exclude pattern %q", pattern)
+		}
+	}
+
+	extensions := job.Extensions
+	if len(extensions) == 0 {
+		extensions = defaultExtensions
+	}
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || ext == "." {
+			return f, fmt.Errorf("extensions contains an empty extension")
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		f.extensions = append(f.extensions, ext)
+	}
+	return f, nil
+}
+
+// relPathはpathのジョブのsourceからの相対パスを"/"区切りで返します。
+func (f fileFilter) relPath(path string) string {
+	rel, err := filepath.Rel(f.root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// hasExtensionはpathが翻訳する拡張子のファイルかを返します。大文字と小文字は区別しません。
+func (f fileFilter) hasExtension(path string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	for _, ext := range f.extensions {
+		if strings.HasSuffix(name, ext) && len(name) > len(ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// includedはpathがincludeのいずれかのパターンに一致するかを返します。includeが空の場合は常にtrueを返します。
+func (f fileFilter) included(path string) bool {
+	if len(f.include) == 0 {
+		return true
+	}
+	rel := f.relPath(path)
+	for _, pattern := range f.include {
+		if doublestar.MatchUnvalidated(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedはpathがexcludeのいずれかのパターンに一致するかを返します。
+// 以前の設定ファイルとの互換性のため、走査したパス(sourceを含むパス)に一致する場合も除外します。
+func (f fileFilter) excluded(path string) bool {
+	rel := f.relPath(path)
+	for _, pattern := range f.exclude {
+		if doublestar.MatchUnvalidated(pattern, rel) || doublestar.MatchUnvalidated(pattern, filepath.ToSlash(path)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNewFileFilterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		job  Job
+		want string
+	}{
+		{name: "invalid include", job: Job{Include: []string{"docs/[a-"}}, want: "invalid include pattern"},
+		{name: "invalid exclude", job: Job{Exclude: []string{"**/{a,b"}}, want: "invalid exclude pattern"},
+		{name: "empty extension", job: Job{Extensions: []string{".md", " "}}, want: "empty extension"},
+		{name: "dot only extension", job: Job{Extensions: []string{"."}}, want: "empty extension"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newFileFilter(tt.job)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("newFileFilter() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileFilter(t *testing.T) {
+	root := filepath.Join("project", "docs")
+	tests := []struct {
+		name                          string
+		job                           Job
+		path                          string // rootからの相対パス
+		extension, included, excluded bool
+	}{
+		{name: "default md", path: "guide/a.md", extension: true, included: true},
+		{name: "default ignores mdx", path: "a.MDX", included: true},
+		{name: "default ignores other extensions", path: "a.txt", included: true},
+		{name: "extension alone is not a file name", path: ".md", included: true},
+		{
+			name: "custom extensions without dot",
+			job:  Job{Extensions: []string{"markdown", ".TXT"}},
+			path: "notes/a.txt", extension: true, included: true,
+		},
+		{
+			name: "mdx is opt-in",
+			job:  Job{Extensions: []string{".md", "mdx"}},
+			path: "a.MDX", extension: true, included: true,
+		},
+		{
+			name: "custom extensions replace defaults",
+			job:  Job{Extensions: []string{".markdown"}},
+			path: "a.md", included: true,
+		},
+		{
+			name: "include relative to source",
+			job:  Job{Include: []string{"guide/**"}},
+			path: "guide/deep/a.md", extension: true, included: true,
+		},
+		{
+			name: "include does not match other directories",
+			job:  Job{Include: []string{"guide/**"}},
+			path: "api/a.md", extension: true,
+		},
+		{
+			name: "include does not match the source directory name",
+			job:  Job{Include: []string{"docs/**"}},
+			path: "a.md", extension: true,
+		},
+		{
+			name: "exclude relative to source",
+			job:  Job{Exclude: []string{"drafts/*.md"}},
+			path: "drafts/a.md", extension: true, included: true, excluded: true,
+		},
+		{
+			name: "exclude is anchored at source",
+			job:  Job{Exclude: []string{"drafts/*.md"}},
+			path: "guide/drafts/a.md", extension: true, included: true,
+		},
+		{
+			name: "legacy exclude with the source path",
+			job:  Job{Exclude: []string{"project/docs/drafts/**"}},
+			path: "drafts/a.md", extension: true, included: true, excluded: true,
+		},
+		{
+			name: "exclude wins over include",
+			job:  Job{Include: []string{"**/*.md"}, Exclude: []string{"**/README.md"}},
+			path: "guide/README.md", extension: true, included: true, excluded: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.job.Source = root
+			f, err := newFileFilter(tt.job)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(root, filepath.FromSlash(tt.path))
+			if got := f.hasExtension(path); got != tt.extension {
+				t.Errorf("hasExtension(%q) = %v, want %v", path, got, tt.extension)
+			}
+			if got := f.included(path); got != tt.included {
+				t.Errorf("included(%q) = %v, want %v", path, got, tt.included)
+			}
+			if got := f.excluded(path); got != tt.excluded {
+				t.Errorf("excluded(%q) = %v, want %v", path, got, tt.excluded)
+			}
+		})
+	}
+}
+
+// ディレクトリのジョブでは、フィルターで選択されたファイルのみを翻訳し、除外したファイルをスキップとして記録する
+func TestTranslateJobFilter(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"docs/a.md":            "A.\n",
+		"docs/guide/b.mdx":     "B.\n",
+		"docs/guide/c.txt":     "C.\n",
+		"docs/drafts/d.md":     "D.\n",
+		"docs/api/e.md":        "E.\n",
+		"docs/guide/README.md": "R.\n",
+	})
+	client := &fakeTranslator{}
+	var progress bytes.Buffer
+	translator := newTestTranslator(t, client, dir, &progress)
+	job := Job{
+		Source:      filepath.Join(dir, "docs"),
+		Destination: filepath.Join(dir, "out"),
+		Include:     []string{"*.md", "guide/**", "drafts/**"},
+		Extensions:  []string{".md", ".mdx", ".txt"},
+		Exclude:     []string{"drafts/**", "**/README.md"},
+	}
+	if err := translator.TranslateJob(context.Background(), job, &Config{TargetLang: "EN"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var translated []string
+	err := filepath.WalkDir(filepath.Join(dir, "out"), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(filepath.Join(dir, "out"), path)
+			translated = append(translated, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(translated)
+	if got := strings.Join(translated, ","); got != "a.md,guide/b.mdx,guide/c.txt" {
+		t.Errorf("translated files = %s", got)
+	}
+
+	var excluded []string
+	for _, f := range translator.Report.Files {
+		if f.SkipReason == SkipExcluded {
+			excluded = append(excluded, filepath.ToSlash(f.Source))
+		}
+	}
+	sort.Strings(excluded)
+	want := filepath.ToSlash(filepath.Join(dir, "docs", "drafts", "d.md")) + "," + filepath.ToSlash(filepath.Join(dir, "docs", "guide", "README.md"))
+	if got := strings.Join(excluded, ","); got != want {
+		t.Errorf("excluded files = %s, want %s", got, want)
+	}
+}
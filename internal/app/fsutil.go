@@ -0,0 +1,52 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomicはファイルを一時ファイルに書き込んだ後にリネームすることで、アトミックに書き込みます。
+// 書き込み中に処理が中断されても、既存のファイルが途中までの内容で上書きされることはありません。
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	// リネームに成功した場合、一時ファイルは存在しないため削除は失敗するが問題ない
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// writeOutputは翻訳結果を出力先にアトミックに書き込みます。
+// skipUnchangedがtrueで、既存のファイルの内容と権限が書き込む内容と同じ場合は書き込まず、
+// 静的サイトジェネレーターなどが参照する更新日時を保ちます。書き込んだ場合はtrueを返します。
+func writeOutput(path string, data []byte, perm os.FileMode, skipUnchanged bool) (bool, error) {
+	if skipUnchanged {
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm() == perm {
+			if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+				return false, nil
+			}
+		}
+	}
+	if err := writeFileAtomic(path, data, perm); err != nil {
+		return false, err
+	}
+	return true, nil
+}
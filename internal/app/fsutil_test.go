@@ -0,0 +1,149 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// tempFilesはdirに残っている書き込み途中の一時ファイルを返します。
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.md")
+	writeFiles(t, dir, map[string]string{"a.md": "old\n"})
+
+	if err := writeFileAtomic(path, []byte("new\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "new\n" {
+		t.Errorf("content = %q, want %q", got, "new\n")
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != 0600 {
+			t.Errorf("permission = %v, want %v", got, os.FileMode(0600))
+		}
+	}
+	if files := tempFiles(t, dir); len(files) > 0 {
+		t.Errorf("temporary files were left behind: %v", files)
+	}
+}
+
+// リネームに失敗した場合も、出力先は元のままで一時ファイルも残らない
+func TestWriteFileAtomicRenameFailure(t *testing.T) {
+	dir := t.TempDir()
+	// 出力先が空でないディレクトリの場合、リネームは失敗する
+	writeFiles(t, dir, map[string]string{"out.md/keep.md": "keep\n"})
+	path := filepath.Join(dir, "out.md")
+
+	if err := writeFileAtomic(path, []byte("new\n"), 0644); err == nil {
+		t.Fatal("writeFileAtomic() = nil, want an error")
+	}
+	if got := readFile(t, filepath.Join(path, "keep.md")); got != "keep\n" {
+		t.Errorf("existing output was modified: %q", got)
+	}
+	if files := tempFiles(t, dir); len(files) > 0 {
+		t.Errorf("temporary files were left behind: %v", files)
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	tests := []struct {
+		name          string
+		data          string
+		perm          os.FileMode
+		skipUnchanged bool
+		wantWritten   bool
+	}{
+		{name: "unchanged", data: "same\n", perm: 0644, skipUnchanged: true},
+		{name: "content changed", data: "changed\n", perm: 0644, skipUnchanged: true, wantWritten: true},
+		{name: "permission changed", data: "same\n", perm: 0600, skipUnchanged: true, wantWritten: true},
+		{name: "skip disabled", data: "same\n", perm: 0644, wantWritten: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" && tt.perm != 0644 {
+				t.Skip("file permissions are not supported on Windows")
+			}
+			dir := t.TempDir()
+			path := filepath.Join(dir, "out.md")
+			writeFiles(t, dir, map[string]string{"out.md": "same\n"})
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+
+			written, err := writeOutput(path, []byte(tt.data), tt.perm, tt.skipUnchanged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if written != tt.wantWritten {
+				t.Errorf("writeOutput() = %v, want %v", written, tt.wantWritten)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 書き込まなかった場合は更新日時を保つ
+			if unchanged := info.ModTime().Equal(old); unchanged == tt.wantWritten {
+				t.Errorf("modification time = %v, want changed %v", info.ModTime(), tt.wantWritten)
+			}
+			if got := readFile(t, path); got != tt.data {
+				t.Errorf("content = %q, want %q", got, tt.data)
+			}
+			if got := info.Mode().Perm(); got != tt.perm {
+				t.Errorf("permission = %v, want %v", got, tt.perm)
+			}
+		})
+	}
+}
+
+// 出力先にはソースファイルと同じ権限を設定する
+func TestTranslateJobPreservesPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not supported on Windows")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"docs/a.md": "A.\n", "docs/b.md": "B.\n"})
+	if err := os.Chmod(filepath.Join(dir, "docs", "a.md"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "docs", "b.md"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var progress bytes.Buffer
+	translator := newTestTranslator(t, &fakeTranslator{}, dir, &progress)
+	job := Job{Source: filepath.Join(dir, "docs"), Destination: filepath.Join(dir, "out")}
+	if err := translator.TranslateJob(context.Background(), job, &Config{TargetLang: "EN"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]os.FileMode{"a.md": 0600, "b.md": 0755} {
+		info, err := os.Stat(filepath.Join(dir, "out", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s permission = %v, want %v", name, got, want)
+		}
+	}
+	if files := tempFiles(t, filepath.Join(dir, "out")); len(files) > 0 {
+		t.Errorf("temporary files were left behind: %v", files)
+	}
+}
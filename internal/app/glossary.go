@@ -0,0 +1,183 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ariela/translate-markdown/internal/deepl"
+)
+
+// glossaryNamePrefixはこのツールが作成した用語集の名前の接頭辞です。
+// 同じ言語ペアの古い用語集を見分けるために使用します。
+const glossaryNamePrefix = "translate-markdown"
+
+// GlossarySyncResultは用語集1件の同期結果を表します。
+type GlossarySyncResult struct {
+	File    string
+	Pair    string
+	Name    string
+	ID      string
+	Created bool
+	Deleted []string
+}
+
+// glossaryPairは用語集の言語ペアを表すキー(例: "JA-EN")を返します。
+// DeepLの用語集は地域を含まない言語コードで管理されるため、"EN-US"は"EN"として扱います。
+func glossaryPair(sourceLang, targetLang string) string {
+	return baseLang(sourceLang) + "-" + baseLang(targetLang)
+}
+
+// baseLangは言語コードから地域を除いた大文字の言語コードを返します。
+func baseLang(lang string) string {
+	base, _, _ := strings.Cut(strings.ToUpper(lang), "-")
+	return base
+}
+
+// loadは用語集ファイルを読み込み、DeepLに登録する内容と名前を返します。
+// 名前には内容のハッシュを含めるため、ファイルが変更された場合のみ新しい用語集が作成されます。
+func (g Glossary) load() (deepl.CreateGlossaryRequest, error) {
+	if g.SourceLang == "" || g.TargetLang == "" || g.File == "" {
+		return deepl.CreateGlossaryRequest{}, fmt.Errorf("glossary requires source_lang, target_lang and file")
+	}
+
+	var format string
+	switch strings.ToLower(filepath.Ext(g.File)) {
+	case ".tsv":
+		format = "tsv"
+	case ".csv":
+		format = "csv"
+	default:
+		return deepl.CreateGlossaryRequest{}, fmt.Errorf("unsupported glossary file format: %s (expected .tsv or .csv)", g.File)
+	}
+
+	data, err := os.ReadFile(g.File)
+	if err != nil {
+		return deepl.CreateGlossaryRequest{}, fmt.Errorf("failed to read glossary file: %w", err)
+	}
+
+	pair := glossaryPair(g.SourceLang, g.TargetLang)
+	hash := sha256.Sum256(append([]byte(pair+"\n"+format+"\n"), data...))
+	return deepl.CreateGlossaryRequest{
+		Name:          fmt.Sprintf("%s:%s:%x", glossaryNamePrefix, pair, hash[:6]),
+		SourceLang:    baseLang(g.SourceLang),
+		TargetLang:    baseLang(g.TargetLang),
+		Entries:       string(data),
+		EntriesFormat: format,
+	}, nil
+}
+
+// validateGlossariesは同じ言語ペアの用語集が重複して定義されていないかを確認します。
+func validateGlossaries(glossaries []Glossary) error {
+	seen := make(map[string]string)
+	for _, g := range glossaries {
+		pair := glossaryPair(g.SourceLang, g.TargetLang)
+		if file, ok := seen[pair]; ok {
+			return fmt.Errorf("duplicate glossary for %s: %s and %s", pair, file, g.File)
+		}
+		seen[pair] = g.File
+	}
+	return nil
+}
+
+// SyncGlossariesは設定された用語集をDeepLに同期します。
+// 内容が同じ用語集が登録済みの場合は何もせず、変更された場合は新しい用語集を作成して同じ言語ペアの古い用語集を削除します。
+func SyncGlossaries(ctx context.Context, api deepl.GlossaryManager, glossaries []Glossary) ([]GlossarySyncResult, error) {
+	if err := validateGlossaries(glossaries); err != nil {
+		return nil, err
+	}
+	existing, err := api.ListGlossaries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []GlossarySyncResult
+	for _, g := range glossaries {
+		req, err := g.load()
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", g.File, err)
+		}
+		pair := glossaryPair(g.SourceLang, g.TargetLang)
+		result := GlossarySyncResult{File: g.File, Pair: pair, Name: req.Name}
+
+		for _, e := range existing {
+			if e.Name == req.Name {
+				result.ID = e.ID
+				break
+			}
+		}
+		if result.ID == "" {
+			created, err := api.CreateGlossary(ctx, req)
+			if err != nil {
+				return results, err
+			}
+			result.ID = created.ID
+			result.Created = true
+		}
+
+		// 同じ言語ペアの古い用語集を削除する
+		pairPrefix := glossaryNamePrefix + ":" + pair + ":"
+		for _, e := range existing {
+			if strings.HasPrefix(e.Name, pairPrefix) && e.ID != result.ID {
+				if err := api.DeleteGlossary(ctx, e.ID); err != nil {
+					return results, err
+				}
+				result.Deleted = append(result.Deleted, e.ID)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// glossaryNameは言語ペアに対応する用語集の名前を返します。対応する用語集がない場合は空文字列を返します。
+// 名前は用語集ファイルの内容から決まるため、DeepLに問い合わせずに用語集を区別できます。
+func glossaryName(glossaries []Glossary, pair string) (string, error) {
+	for _, g := range glossaries {
+		if glossaryPair(g.SourceLang, g.TargetLang) != pair {
+			continue
+		}
+		req, err := g.load()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", g.File, err)
+		}
+		return req.Name, nil
+	}
+	return "", nil
+}
+
+// ResolveGlossariesは設定された用語集に対応する同期済みの用語集IDを、言語ペアごとに返します。
+// 用語集ファイルが同期後に変更されている場合はエラーを返します。
+func ResolveGlossaries(ctx context.Context, api deepl.GlossaryManager, glossaries []Glossary) (map[string]string, error) {
+	if len(glossaries) == 0 {
+		return nil, nil
+	}
+	if err := validateGlossaries(glossaries); err != nil {
+		return nil, err
+	}
+	existing, err := api.ListGlossaries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]string, len(glossaries))
+	for _, g := range glossaries {
+		req, err := g.load()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", g.File, err)
+		}
+		for _, e := range existing {
+			if e.Name == req.Name {
+				ids[glossaryPair(g.SourceLang, g.TargetLang)] = e.ID
+				break
+			}
+		}
+		if _, ok := ids[glossaryPair(g.SourceLang, g.TargetLang)]; !ok {
+			return nil, fmt.Errorf("glossary %s is not synchronized with DeepL; run `translate-markdown glossary sync`", g.File)
+		}
+	}
+	return ids, nil
+}
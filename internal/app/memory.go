@@ -0,0 +1,123 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ariela/translate-markdown/internal/deepl"
+)
+
+const memoryFileName = ".translation_memory.json"
+
+// TranslationMemoryはセグメント単位の翻訳結果を保持します。
+// 翻訳元テキストと翻訳設定が同じセグメントは、APIを呼び出さずに前回の翻訳結果を再利用します。
+// 複数のワーカーから同時に使用できます。
+type TranslationMemory struct {
+	path   string
+	mu     sync.Mutex
+	saveMu sync.Mutex
+	// キー: 正規化したテキストと翻訳設定のハッシュ, 値: 前後の空白を除いた翻訳結果
+	Entries map[string]string `json:"entries"`
+}
+
+// NewTranslationMemoryは新しいTranslationMemoryインスタンスを作成し、既存のファイルを読み込みます。
+func NewTranslationMemory(projectRoot string) (*TranslationMemory, error) {
+	m := &TranslationMemory{
+		path:    filepath.Join(projectRoot, memoryFileName),
+		Entries: make(map[string]string),
+	}
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		// ファイルが存在しない場合はエラーとしない
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]string)
+	}
+	return m, nil
+}
+
+// Lookupはテキストの翻訳結果を検索します。
+// 見つかった場合は、textの前後の空白を保ったままの翻訳結果を返します。
+func (m *TranslationMemory) Lookup(text string, opts deepl.Options) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	translation, ok := m.Entries[memoryKey(text, opts)]
+	if !ok {
+		return "", false
+	}
+	return wrapSpaces(text, translation), true
+}
+
+// Storeはテキストの翻訳結果を記録し、textの前後の空白を保った翻訳結果を返します。
+// キャッシュから再利用した場合と同じ結果になるよう、前後の空白は翻訳元に合わせます。
+func (m *TranslationMemory) Store(text string, opts deepl.Options, translation string) string {
+	translation = strings.TrimFunc(translation, unicode.IsSpace)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries[memoryKey(text, opts)] = translation
+	return wrapSpaces(text, translation)
+}
+
+// Saveは現在の翻訳メモリをディスクにアトミックに保存します。
+func (m *TranslationMemory) Save() error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.path, data, 0644)
+}
+
+// memoryKeyは正規化したテキストと、翻訳結果に影響する設定からキーを作成します。
+func memoryKey(text string, opts deepl.Options) string {
+	h := sha256.New()
+	for _, v := range []string{
+		normalizeSegment(text),
+		opts.SourceLang,
+		opts.TargetLang,
+		opts.Formality,
+		opts.Context,
+		opts.GlossaryID,
+		opts.TagHandling,
+	} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeSegmentは前後の空白を除き、連続する空白を1つにまとめます。
+func normalizeSegment(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// alignSpacesはtranslationの前後の空白を、originalの前後の空白に置き換えます。
+// Storeの戻り値と同じ文字列になります。
+func alignSpaces(original, translation string) string {
+	return wrapSpaces(original, strings.TrimFunc(translation, unicode.IsSpace))
+}
+
+// wrapSpacesはtranslationの前後に、originalの前後の空白を付け加えます。
+func wrapSpaces(original, translation string) string {
+	trimmed := strings.TrimLeftFunc(original, unicode.IsSpace)
+	leading := original[:len(original)-len(trimmed)]
+	trailing := trimmed[len(strings.TrimRightFunc(trimmed, unicode.IsSpace)):]
+	return leading + translation + trailing
+}
@@ -0,0 +1,208 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// orphansに指定できる、ソースが削除された翻訳済みファイルの扱いです。
+const (
+	// orphansListは翻訳済みファイルを一覧に表示するのみで、削除しません。
+	orphansList = "list"
+	// orphansDeleteは翻訳済みファイルを削除します。
+	orphansDelete = "delete"
+	// orphansTrashは翻訳済みファイルをゴミ箱のディレクトリに移動します。
+	orphansTrash = "trash"
+)
+
+// defaultTrashDirはorphans_trashを省略した場合のゴミ箱のディレクトリです。
+// プロジェクトルートからの相対パスです。
+const defaultTrashDir = ".translation_trash"
+
+// Orphanはソースファイルが削除された翻訳済みファイルです。
+type Orphan struct {
+	Source      string
+	Destination string
+	TargetLang  string
+	// Actionは翻訳済みファイルの扱い(orphansの値)です。
+	Action string
+}
+
+// validateOrphansはorphansの値が正しいかを確認します。空の場合は確認を行いません。
+func validateOrphans(policy string) error {
+	switch policy {
+	case "", orphansList, orphansDelete, orphansTrash:
+		return nil
+	}
+	return fmt.Errorf("invalid orphans %q (expected %q, %q or %q)", policy, orphansList, orphansDelete, orphansTrash)
+}
+
+// findOrphansはディレクトリのジョブで以前に翻訳され、ソースファイルが削除されたキャッシュエントリを返します。
+// ファイル名ではなくキャッシュに記録されたソースと出力先の組み合わせで判断するため、
+// 他のジョブや手動で作成されたファイルを対象にすることはありません。
+func (t *Translator) findOrphans(job Job, targets []translationTarget) ([]CacheEntry, error) {
+	var orphans []CacheEntry
+	for _, entry := range t.jobEntries(job, targets) {
+		if _, err := os.Lstat(entry.Source); err == nil {
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		orphans = append(orphans, entry)
+	}
+	return orphans, nil
+}
+
+// jobEntriesはディレクトリのジョブの現在の設定で出力されるキャッシュエントリを返します。
+// 出力先の設定を変更した場合など、現在のジョブが出力しないファイルのエントリは含めません。
+func (t *Translator) jobEntries(job Job, targets []translationTarget) []CacheEntry {
+	langs := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		langs[target.opts.TargetLang] = true
+	}
+
+	var entries []CacheEntry
+	for _, entry := range t.cache.entries() {
+		if !langs[entry.TargetLang] || !withinDir(job.Source, entry.Source) {
+			continue
+		}
+		destPath, err := destinationPath(job, entry.TargetLang, entry.Source)
+		if err != nil || filepath.Clean(destPath) != filepath.Clean(entry.Destination) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// withinDirはpathがdirの配下にあるかを返します。
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// handleOrphansはソースファイルが削除された翻訳済みファイルを、ジョブのorphansの設定に従って処理します。
+// 削除または移動した場合と、翻訳済みファイルが既に存在しないか他のソースの翻訳済みファイルになっている場合は、
+// キャッシュエントリも削除します。翻訳後に変更された翻訳済みファイルは、削除や移動をせずに一覧に表示するのみとします。
+func (t *Translator) handleOrphans(job Job, targets []translationTarget) error {
+	orphans, err := t.findOrphans(job, targets)
+	if err != nil {
+		return fmt.Errorf("failed to find orphaned translations: %w", err)
+	}
+
+	for _, entry := range orphans {
+		key := CacheKey{Source: entry.Source, Destination: entry.Destination, TargetLang: entry.TargetLang}
+		// 翻訳済みファイルが存在しない場合や、他のソースの翻訳済みファイルとして使われている場合は、キャッシュエントリのみを削除する
+		if _, err := os.Lstat(entry.Destination); errors.Is(err, fs.ErrNotExist) || t.isLiveOutput(entry) {
+			if !t.dryRun {
+				t.cache.Remove(key)
+			}
+			continue
+		}
+
+		action := job.Orphans
+		reason, err := modifiedReason(entry)
+		if err != nil {
+			t.Report.AddError(entry.Destination, fmt.Errorf("failed to check orphaned translation: %w", err))
+			continue
+		}
+		if reason != "" {
+			// 削除や移動はせず、一覧に表示するのみとする
+			action = orphansList
+		}
+
+		orphan := Orphan{Source: entry.Source, Destination: entry.Destination, TargetLang: entry.TargetLang, Action: action}
+		if t.dryRun {
+			t.Estimate.addOrphan(orphan)
+			continue
+		}
+
+		switch action {
+		case orphansList:
+			if reason != "" {
+				fmt.Fprintf(t.progress, "Kept orphaned translation: %s (source %s was removed, %s)\n", entry.Destination, entry.Source, reason)
+			} else {
+				fmt.Fprintf(t.progress, "Orphaned translation: %s (source %s was removed)\n", entry.Destination, entry.Source)
+			}
+		case orphansDelete:
+			if err := os.Remove(entry.Destination); err != nil {
+				t.Report.AddError(entry.Destination, fmt.Errorf("failed to delete orphaned translation: %w", err))
+				continue
+			}
+			fmt.Fprintf(t.progress, "Deleted orphaned translation: %s (source %s was removed)\n", entry.Destination, entry.Source)
+			t.cache.Remove(key)
+		case orphansTrash:
+			trashPath, err := t.moveToTrash(job, entry.Destination)
+			if err != nil {
+				t.Report.AddError(entry.Destination, fmt.Errorf("failed to move orphaned translation to trash: %w", err))
+				continue
+			}
+			fmt.Fprintf(t.progress, "Moved orphaned translation: %s -> %s (source %s was removed)\n", entry.Destination, trashPath, entry.Source)
+			t.cache.Remove(key)
+		}
+		t.Report.AddOrphan()
+	}
+	return nil
+}
+
+// isLiveOutputは翻訳済みファイルが、ソースが存在する他のキャッシュエントリの出力先になっているかを返します。
+func (t *Translator) isLiveOutput(entry CacheEntry) bool {
+	for _, other := range t.cache.entries() {
+		if other.Source == entry.Source || filepath.Clean(other.Destination) != filepath.Clean(entry.Destination) {
+			continue
+		}
+		if _, err := os.Lstat(other.Source); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// modifiedReasonは翻訳済みファイルが翻訳後に変更されている場合に、削除や移動をせずに残す理由を返します。
+// 書き込んだ内容のハッシュが記録されていない場合は、変更されていないことを確認できないため残します。
+// 変更されていない場合は空文字列を返します。
+func modifiedReason(entry CacheEntry) (string, error) {
+	if entry.OutputHash == "" {
+		return "output hash was not recorded", nil
+	}
+	hash, err := CalculateMD5(entry.Destination)
+	if err != nil {
+		return "", err
+	}
+	if hash != entry.OutputHash {
+		return "modified since it was translated", nil
+	}
+	return "", nil
+}
+
+// moveToTrashは翻訳済みファイルをゴミ箱のディレクトリに移動し、移動先のパスを返します。
+// 移動先では、プロジェクトルートからの相対パスを保ちます。
+func (t *Translator) moveToTrash(job Job, path string) (string, error) {
+	trashDir := job.OrphansTrash
+	if trashDir == "" {
+		trashDir = filepath.Join(t.projectRoot, defaultTrashDir)
+	}
+
+	rel := filepath.Base(path)
+	absRoot, rootErr := filepath.Abs(t.projectRoot)
+	absPath, pathErr := filepath.Abs(path)
+	if rootErr == nil && pathErr == nil && withinDir(absRoot, absPath) {
+		rel, _ = filepath.Rel(absRoot, absPath)
+	}
+
+	trashPath := filepath.Join(trashDir, rel)
+	if err := os.MkdirAll(filepath.Dir(trashPath), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(path, trashPath); err != nil {
+		return "", err
+	}
+	return trashPath, nil
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// orphanFixtureはディレクトリのジョブを一度翻訳した後、ソースファイルを削除したプロジェクトです。
+type orphanFixture struct {
+	dir string
+	job Job
+}
+
+// newOrphanFixtureはdocs/a.mdとdocs/guide/b.mdをout/に翻訳した後、docs/guide/b.mdを削除します。
+func newOrphanFixture(t *testing.T) orphanFixture {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"docs/a.md":       "A.\n",
+		"docs/guide/b.md": "B.\n",
+	})
+	f := orphanFixture{
+		dir: dir,
+		job: Job{Source: filepath.Join(dir, "docs"), Destination: filepath.Join(dir, "out")},
+	}
+	f.run(t, "")
+	if err := os.Remove(filepath.Join(dir, "docs", "guide", "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// runはorphansの設定でジョブを翻訳し、キャッシュを保存した後のTranslatorと進捗の出力を返します。
+func (f orphanFixture) run(t *testing.T, orphans string) (*Translator, string) {
+	t.Helper()
+	var progress bytes.Buffer
+	translator := newTestTranslator(t, &fakeTranslator{}, f.dir, &progress)
+	job := f.job
+	job.Orphans = orphans
+	if err := translator.TranslateJob(context.Background(), job, &Config{TargetLang: "EN"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := translator.SaveCache(); err != nil {
+		t.Fatal(err)
+	}
+	return translator, progress.String()
+}
+
+func (f orphanFixture) path(name string) string {
+	return filepath.Join(f.dir, filepath.FromSlash(name))
+}
+
+// cachedDestinationsはキャッシュに記録されている出力先のパスを返します。
+func (f orphanFixture) cachedDestinations(t *testing.T) []string {
+	t.Helper()
+	cache, err := NewCache(f.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var destinations []string
+	for _, entry := range cache.entries() {
+		rel, err := filepath.Rel(f.dir, entry.Destination)
+		if err != nil {
+			t.Fatal(err)
+		}
+		destinations = append(destinations, filepath.ToSlash(rel))
+	}
+	return destinations
+}
+
+func exists(t *testing.T, path string) bool {
+	t.Helper()
+	_, err := os.Lstat(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestHandleOrphans(t *testing.T) {
+	tests := []struct {
+		name    string
+		orphans string
+		// editがtrueの場合は、翻訳済みファイルを手動で編集してから実行する
+		edit bool
+		// keptは翻訳済みファイルが残るかどうかです。
+		kept bool
+		// trashedは翻訳済みファイルの移動先(プロジェクトルートからの相対パス)です。
+		trashed string
+		output  string
+	}{
+		{name: "list", orphans: orphansList, kept: true, output: "Orphaned translation: "},
+		{name: "delete", orphans: orphansDelete, output: "Deleted orphaned translation: "},
+		{name: "trash", orphans: orphansTrash, trashed: ".translation_trash/out/guide/b.md", output: "Moved orphaned translation: "},
+		{name: "delete edited", orphans: orphansDelete, edit: true, kept: true, output: "modified since it was translated"},
+		{name: "trash edited", orphans: orphansTrash, edit: true, kept: true, output: "modified since it was translated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOrphanFixture(t)
+			orphan := f.path("out/guide/b.md")
+			// キャッシュに記録されていない、手動で作成したファイル
+			manual := f.path("out/guide/manual.md")
+			writeFiles(t, f.dir, map[string]string{"out/guide/manual.md": "manual\n"})
+			content := readFile(t, orphan)
+			if tt.edit {
+				content = "edited by hand\n"
+				writeFiles(t, f.dir, map[string]string{"out/guide/b.md": content})
+			}
+
+			translator, progress := f.run(t, tt.orphans)
+			if !strings.Contains(progress, tt.output) {
+				t.Errorf("progress output = %q, want %q", progress, tt.output)
+			}
+			if got := translator.Report.OrphanCount; got != 1 {
+				t.Errorf("OrphanCount = %d, want 1", got)
+			}
+
+			if got := exists(t, orphan); got != tt.kept {
+				t.Errorf("orphaned translation exists = %v, want %v", got, tt.kept)
+			}
+			if tt.kept && readFile(t, orphan) != content {
+				t.Errorf("orphaned translation was modified: %q", readFile(t, orphan))
+			}
+			if tt.trashed != "" && readFile(t, f.path(tt.trashed)) != content {
+				t.Errorf("trashed file = %q, want %q", readFile(t, f.path(tt.trashed)), content)
+			}
+			if !exists(t, manual) || !exists(t, f.path("out/a.md")) {
+				t.Error("a file that is not an orphaned translation was removed")
+			}
+
+			// 残した翻訳済みファイルのみ、次の実行でも確認できるようキャッシュエントリを残す
+			want := "out/a.md"
+			if tt.kept {
+				want += ",out/guide/b.md"
+			}
+			if got := strings.Join(f.cachedDestinations(t), ","); got != want {
+				t.Errorf("cached destinations = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// 他のソースの翻訳済みファイルとして使われている出力先は、削除もゴミ箱への移動もしない
+func TestHandleOrphansLiveDestination(t *testing.T) {
+	for _, orphans := range []string{orphansDelete, orphansTrash} {
+		t.Run(orphans, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				"docs/a.md":       "A.\n",
+				"docs/guide/a.md": "Guide A.\n",
+			})
+			// どちらのソースも out/a.md に出力する
+			f := orphanFixture{dir: dir, job: Job{Source: filepath.Join(dir, "docs"), Destination: filepath.Join(dir, "out", "{name}.md")}}
+			f.run(t, "")
+			if err := os.Remove(filepath.Join(dir, "docs", "guide", "a.md")); err != nil {
+				t.Fatal(err)
+			}
+			// 残ったソースは変更されていないため、次の実行では翻訳されない
+			translator, _ := f.run(t, orphans)
+
+			if !exists(t, f.path("out/a.md")) {
+				t.Fatal("live translation was removed")
+			}
+			if got := translator.Report.OrphanCount; got != 0 {
+				t.Errorf("OrphanCount = %d, want 0", got)
+			}
+			if got := strings.Join(f.cachedDestinations(t), ","); got != "out/a.md" {
+				t.Errorf("cached destinations = %s, want only the live entry", got)
+			}
+		})
+	}
+}
+
+// ドライランでは翻訳済みファイルを変更せず、処理の予定を見積もりに記録する
+func TestHandleOrphansDryRun(t *testing.T) {
+	f := newOrphanFixture(t)
+	writeFiles(t, f.dir, map[string]string{"out/a.md": "edited\n"})
+	if err := os.Remove(f.path("docs/a.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	var progress bytes.Buffer
+	translator := newTestTranslator(t, &fakeTranslator{}, f.dir, &progress)
+	translator.EnableDryRun()
+	job := f.job
+	job.Orphans = orphansDelete
+	if err := translator.TranslateJob(context.Background(), job, &Config{TargetLang: "EN"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists(t, f.path("out/a.md")) || !exists(t, f.path("out/guide/b.md")) {
+		t.Error("dry run removed an orphaned translation")
+	}
+	var actions []string
+	for _, job := range translator.Estimate.Jobs {
+		for _, o := range job.Orphans {
+			rel, _ := filepath.Rel(f.dir, o.Destination)
+			actions = append(actions, filepath.ToSlash(rel)+"="+o.Action)
+		}
+	}
+	if got := strings.Join(actions, ","); got != "out/a.md=list,out/guide/b.md=delete" {
+		t.Errorf("estimated orphans = %s", got)
+	}
+}
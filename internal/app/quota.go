@@ -0,0 +1,78 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ariela/translate-markdown/internal/deepl"
+)
+
+// ErrQuotaExceededは翻訳に必要な文字数がアカウントの残りの文字数を超えることを表します。
+var ErrQuotaExceeded = errors.New("not enough DeepL quota")
+
+// QuotaPlanは残りの文字数と予算に基づいて、今回の実行で翻訳するファイルを決定した結果です。
+type QuotaPlan struct {
+	// Requiredは全てのファイルの翻訳に必要な文字数です。
+	Required int64
+	// Remainingはアカウントの当期の残りの文字数です。
+	Remaining int64
+	// Limitは今回の実行で翻訳する文字数の上限です。
+	Limit int64
+	// Deferredは上限を超えるため今回は翻訳しないファイルです。
+	Deferred []FileEstimate
+
+	// allowedは翻訳するファイルのキャッシュキーです。nilの場合は全てのファイルを翻訳します。
+	allowed map[string]bool
+}
+
+// PlanQuotaはドライランの見積もりとアカウントの使用状況から、今回の実行で翻訳するファイルを決定します。
+// 上限はアカウントの残りの文字数と、budget(0以下の場合は無制限)の小さい方です。
+// 必要な文字数が上限を超える場合、ジョブの順、ファイルのパスの順に上限に収まるファイルまでを翻訳します。
+// ただし、budgetが指定されておらず残りの文字数が不足する場合は、ErrQuotaExceededを返します。
+func PlanQuota(est *Estimate, usage deepl.Usage, budget int64) (*QuotaPlan, error) {
+	files := est.orderedFiles()
+	plan := &QuotaPlan{Remaining: usage.Remaining()}
+	for _, f := range files {
+		plan.Required += int64(f.Chars)
+	}
+	plan.Limit = plan.Remaining
+	if budget > 0 && budget < plan.Limit {
+		plan.Limit = budget
+	}
+
+	if plan.Required <= plan.Limit {
+		return plan, nil
+	}
+	if budget <= 0 {
+		return plan, fmt.Errorf("%w: %d characters required, %d remaining", ErrQuotaExceeded, plan.Required, plan.Remaining)
+	}
+
+	// 上限を超えたファイル以降は翻訳しないことで、次回の実行で続きから翻訳されるようにする
+	plan.allowed = make(map[string]bool)
+	var used int64
+	full := false
+	for _, f := range files {
+		key := CacheKey{Source: f.Source, Destination: f.Destination, TargetLang: f.TargetLang}
+		if !full && used+int64(f.Chars) <= plan.Limit {
+			used += int64(f.Chars)
+			plan.allowed[key.String()] = true
+			continue
+		}
+		full = true
+		// APIを呼び出さないファイルは上限に関係なく処理する
+		if f.Chars == 0 {
+			plan.allowed[key.String()] = true
+			continue
+		}
+		plan.Deferred = append(plan.Deferred, f)
+	}
+	return plan, nil
+}
+
+// Allowsは指定されたファイルを今回の実行で翻訳するかを返します。
+func (p *QuotaPlan) Allows(key CacheKey) bool {
+	if p == nil || p.allowed == nil {
+		return true
+	}
+	return p.allowed[key.String()]
+}
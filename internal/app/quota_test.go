@@ -0,0 +1,126 @@
+package app
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ariela/translate-markdown/internal/deepl"
+)
+
+// quotaEstimateは2つのジョブの見積もりです。ファイルはワーカーの処理順を模して、パスの順に並んでいません。
+func quotaEstimate() *Estimate {
+	return &Estimate{Jobs: []JobEstimate{
+		{Source: "docs", Files: []FileEstimate{
+			{Source: "docs/c.md", Destination: "out/c.md", TargetLang: "EN", Chars: 30},
+			{Source: "docs/a.md", Destination: "out/a.md", TargetLang: "EN", Chars: 40},
+			{Source: "docs/b.md", Destination: "out/b.md", TargetLang: "EN", SkipReason: SkipUnchanged},
+			{Source: "docs/d.md", Destination: "out/d.md", TargetLang: "EN", ReusedSegments: 2},
+		}},
+		{Source: "README.md", Files: []FileEstimate{
+			{Source: "README.md", Destination: "README.en.md", TargetLang: "EN", Chars: 10},
+		}},
+	}}
+}
+
+func TestPlanQuota(t *testing.T) {
+	tests := []struct {
+		name      string
+		usage     deepl.Usage
+		budget    int64
+		wantLimit int64
+		// allowedは翻訳するファイル、deferredは次回以降に翻訳するファイルのソースです。
+		allowed  []string
+		deferred []string
+	}{
+		{
+			name:      "enough quota",
+			usage:     deepl.Usage{CharacterCount: 0, CharacterLimit: 100},
+			wantLimit: 100,
+			allowed:   []string{"docs/a.md", "docs/c.md", "docs/d.md", "README.md"},
+		},
+		{
+			name:      "budget within quota",
+			usage:     deepl.Usage{CharacterCount: 0, CharacterLimit: 100},
+			budget:    80,
+			wantLimit: 80,
+			allowed:   []string{"docs/a.md", "docs/c.md", "docs/d.md", "README.md"},
+		},
+		{
+			// ジョブの順、パスの順に上限まで翻訳し、上限を超えたファイル以降は小さくても翻訳しない
+			name:      "budget defers files in order",
+			usage:     deepl.Usage{CharacterCount: 0, CharacterLimit: 100},
+			budget:    50,
+			wantLimit: 50,
+			allowed:   []string{"docs/a.md", "docs/d.md"},
+			deferred:  []string{"docs/c.md", "README.md"},
+		},
+		{
+			name:      "remaining quota below budget",
+			usage:     deepl.Usage{CharacterCount: 30, CharacterLimit: 100},
+			budget:    1000,
+			wantLimit: 70,
+			allowed:   []string{"docs/a.md", "docs/c.md", "docs/d.md"},
+			deferred:  []string{"README.md"},
+		},
+		{
+			name:      "first file over budget",
+			usage:     deepl.Usage{CharacterCount: 0, CharacterLimit: 100},
+			budget:    5,
+			wantLimit: 5,
+			allowed:   []string{"docs/d.md"},
+			deferred:  []string{"docs/a.md", "docs/c.md", "README.md"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			est := quotaEstimate()
+			plan, err := PlanQuota(est, tt.usage, tt.budget)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan.Required != 80 || plan.Remaining != tt.usage.Remaining() || plan.Limit != tt.wantLimit {
+				t.Errorf("plan = required %d, remaining %d, limit %d; want 80, %d, %d",
+					plan.Required, plan.Remaining, plan.Limit, tt.usage.Remaining(), tt.wantLimit)
+			}
+
+			var allowed []string
+			for _, f := range est.orderedFiles() {
+				if plan.Allows(CacheKey{Source: f.Source, Destination: f.Destination, TargetLang: f.TargetLang}) {
+					allowed = append(allowed, f.Source)
+				}
+			}
+			if !reflect.DeepEqual(allowed, tt.allowed) {
+				t.Errorf("allowed = %q, want %q", allowed, tt.allowed)
+			}
+			var deferred []string
+			for _, f := range plan.Deferred {
+				deferred = append(deferred, f.Source)
+			}
+			if !reflect.DeepEqual(deferred, tt.deferred) {
+				t.Errorf("deferred = %q, want %q", deferred, tt.deferred)
+			}
+		})
+	}
+}
+
+// char_budgetを指定せずに残りの文字数が不足する場合は、一部のみを翻訳せずに中止する
+func TestPlanQuotaAbort(t *testing.T) {
+	for _, usage := range []deepl.Usage{
+		{CharacterCount: 30, CharacterLimit: 100},
+		{CharacterCount: 120, CharacterLimit: 100},
+	} {
+		_, err := PlanQuota(quotaEstimate(), usage, 0)
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("PlanQuota(%+v) error = %v, want ErrQuotaExceeded", usage, err)
+		}
+	}
+}
+
+// 残りの文字数を確認しない場合(計画がnil)は、全てのファイルを翻訳する
+func TestQuotaPlanAllowsWithoutLimit(t *testing.T) {
+	var plan *QuotaPlan
+	if !plan.Allows(CacheKey{Source: "docs/a.md"}) {
+		t.Error("nil plan does not allow a file")
+	}
+}
@@ -2,7 +2,11 @@ package app
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"sync"
+	"time"
 )
 
 // TranslationErrorはファイルごとのエラー情報を保持します。
@@ -11,69 +15,180 @@ type TranslationError struct {
 	Err      error
 }
 
+// FileStatusはファイルの処理結果の種類です。
+type FileStatus string
+
+const (
+	StatusSuccess     FileStatus = "success"
+	StatusSkipped     FileStatus = "skipped"
+	StatusFailed      FileStatus = "failed"
+	StatusInterrupted FileStatus = "interrupted"
+)
+
+// スキップされた理由です。
+const (
+	// SkipUnchangedはソースファイルと翻訳設定が前回の翻訳から変更されていないことを表します。
+	SkipUnchanged = "unchanged"
+	// SkipExcludedはファイルがexcludeのパターンに一致したことを表します。
+	SkipExcluded = "excluded"
+	// SkipBudgetは文字数の上限を超えるため、次回の実行に持ち越したことを表します。
+	SkipBudget = "budget"
+)
+
+// FileResultはソースファイルを1つの言語に翻訳した結果です。
+// 特定の翻訳先に対応しない結果(除外されたファイルなど)では、DestinationとTargetLangは空になります。
+type FileResult struct {
+	Source      string
+	Destination string
+	TargetLang  string
+	Status      FileStatus
+	// CharsはAPIに送信した文字数です。
+	Chars          int
+	ReusedSegments int
+	Duration       time.Duration
+	// SkipReasonはスキップされた理由です。スキップされていない場合は空になります。
+	SkipReason string
+	// RetriesはAPIへのリクエストを再送した回数です。
+	Retries int
+	// ErrorCategoryはエラーの種類です。空の場合、AddFileがErrから判断します。
+	ErrorCategory string
+	Err           error
+}
+
 // Reportは翻訳処理の結果を集計します。
 type Report struct {
-	mu              sync.Mutex
-	SuccessCount    int
-	SkippedCount    int
-	FailedCount     int
-	TranslatedChars int
-	Errors          []TranslationError
+	mu               sync.Mutex
+	started          time.Time
+	SuccessCount     int
+	SkippedCount     int
+	FailedCount      int
+	InterruptedCount int
+	TranslatedChars  int
+	ReusedSegments   int
+	OrphanCount      int
+	Errors           []TranslationError
+	Files            []FileResult
 }
 
 // NewReportは新しいReportインスタンスを作成します。
 func NewReport() *Report {
 	return &Report{
-		Errors: make([]TranslationError, 0),
+		started: time.Now(),
+		Errors:  make([]TranslationError, 0),
 	}
 }
 
-// IncrementSuccessは成功カウントを1増やします。
-func (r *Report) IncrementSuccess() {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.SuccessCount++
-}
+// AddFileはファイルの処理結果を記録し、結果の種類に応じたカウントを増やします。
+func (r *Report) AddFile(res FileResult) {
+	if res.Status == StatusFailed && res.ErrorCategory == "" {
+		res.ErrorCategory = ErrorCategory(res.Err)
+	}
 
-// IncrementSkippedはスキップカウントを1増やします。
-func (r *Report) IncrementSkipped() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.SkippedCount++
+	switch res.Status {
+	case StatusSuccess:
+		r.SuccessCount++
+	case StatusSkipped:
+		r.SkippedCount++
+	case StatusFailed:
+		r.FailedCount++
+		err := res.Err
+		if res.TargetLang != "" {
+			err = fmt.Errorf("%s: %w", res.TargetLang, err)
+		}
+		r.Errors = append(r.Errors, TranslationError{FilePath: res.Source, Err: err})
+	case StatusInterrupted:
+		r.InterruptedCount++
+	}
+	if res.Status == StatusSuccess {
+		r.TranslatedChars += res.Chars
+		r.ReusedSegments += res.ReusedSegments
+	}
+	r.Files = append(r.Files, res)
 }
 
 // AddErrorは失敗カウントを1増やし、エラー情報を記録します。
+// ディレクトリの走査やジョブの設定など、特定の翻訳先に対応しないエラーの記録に使用します。
 func (r *Report) AddError(filePath string, err error) {
+	r.AddFile(FileResult{Source: filePath, Status: StatusFailed, Err: err})
+}
+
+// AddOrphanはソースファイルが削除された翻訳済みファイルのカウントを1増やします。
+func (r *Report) AddOrphan() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.FailedCount++
-	r.Errors = append(r.Errors, TranslationError{FilePath: filePath, Err: err})
+	r.OrphanCount++
 }
 
-// AddCharsは翻訳した文字数を加算します。
-func (r *Report) AddChars(count int) {
+// CountFilesは処理結果のうち、条件に一致するものの数を返します。
+func (r *Report) CountFiles(match func(FileResult) bool) int {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.TranslatedChars += count
+	count := 0
+	for _, f := range r.Files {
+		if match(f) {
+			count++
+		}
+	}
+	return count
 }
 
 // Printは集計結果をコンソールに出力します。
 func (r *Report) Print() {
+	r.printText(os.Stdout)
+}
+
+func (r *Report) printText(w io.Writer) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	fmt.Println("\n--- Translation Summary ---")
-	fmt.Printf("✅ Successful: %d\n", r.SuccessCount)
-	fmt.Printf("⏩ Skipped:    %d\n", r.SkippedCount)
-	fmt.Printf("❌ Failed:     %d\n", r.FailedCount)
-	fmt.Printf("🔤 Characters: %d\n", r.TranslatedChars)
-	fmt.Println("---------------------------")
+	fmt.Fprintln(w, "\n--- Translation Summary ---")
+	fmt.Fprintf(w, "✅ Successful: %d\n", r.SuccessCount)
+	fmt.Fprintf(w, "⏩ Skipped:    %d\n", r.SkippedCount)
+	fmt.Fprintf(w, "❌ Failed:     %d\n", r.FailedCount)
+	if r.InterruptedCount > 0 {
+		fmt.Fprintf(w, "⏹️ Interrupted: %d\n", r.InterruptedCount)
+	}
+	fmt.Fprintf(w, "🔤 Characters: %d\n", r.TranslatedChars)
+	fmt.Fprintf(w, "♻️ Reused:     %d segments\n", r.ReusedSegments)
+	if r.OrphanCount > 0 {
+		fmt.Fprintf(w, "🧹 Orphans:    %d\n", r.OrphanCount)
+	}
+	fmt.Fprintln(w, "---------------------------")
+
+	r.printErrors(w)
+}
+
+// PrintErrorsはエラーが発生したファイルの一覧のみをコンソールに出力します。
+func (r *Report) PrintErrors() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.printErrors(os.Stdout)
+}
 
+func (r *Report) printErrors(w io.Writer) {
 	if r.FailedCount > 0 {
-		fmt.Println("\nErrors:")
+		fmt.Fprintln(w, "\nErrors:")
 		for _, e := range r.Errors {
-			fmt.Printf("- File: %s\n  Error: %v\n", e.FilePath, e.Err)
+			fmt.Fprintf(w, "- File: %s\n  Error: %v\n", e.FilePath, e.Err)
 		}
-		fmt.Println("---------------------------")
+		fmt.Fprintln(w, "---------------------------")
 	}
 }
+
+// sortedFilesはワーカーの処理順に依存しないよう、パスと言語の順に並べたファイルの処理結果を返します。
+// r.muを取得した状態で呼び出す必要があります。
+func (r *Report) sortedFiles() []FileResult {
+	files := append([]FileResult(nil), r.Files...)
+	sort.SliceStable(files, func(a, b int) bool {
+		if files[a].Source != files[b].Source {
+			return files[a].Source < files[b].Source
+		}
+		if files[a].Destination != files[b].Destination {
+			return files[a].Destination < files[b].Destination
+		}
+		return files[a].TargetLang < files[b].TargetLang
+	})
+	return files
+}
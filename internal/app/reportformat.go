@@ -0,0 +1,220 @@
+package app
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// レポートの出力形式です。
+const (
+	ReportFormatText  = "text"
+	ReportFormatJSON  = "json"
+	ReportFormatJUnit = "junit"
+)
+
+// ValidateReportFormatはレポートの出力形式が正しいかを確認します。
+func ValidateReportFormat(format string) error {
+	switch format {
+	case ReportFormatText, ReportFormatJSON, ReportFormatJUnit:
+		return nil
+	}
+	return fmt.Errorf("invalid report format %q (expected %q, %q or %q)", format, ReportFormatText, ReportFormatJSON, ReportFormatJUnit)
+}
+
+// Writeは指定された形式でレポートをwに書き込みます。
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case ReportFormatText:
+		r.printText(w)
+		return nil
+	case ReportFormatJSON:
+		return r.writeJSON(w)
+	case ReportFormatJUnit:
+		return r.writeJUnit(w)
+	}
+	return ValidateReportFormat(format)
+}
+
+// jsonReportはJSON形式のレポートです。
+type jsonReport struct {
+	Summary jsonSummary `json:"summary"`
+	Files   []jsonFile  `json:"files"`
+}
+
+type jsonSummary struct {
+	Successful      int     `json:"successful"`
+	Skipped         int     `json:"skipped"`
+	Failed          int     `json:"failed"`
+	Interrupted     int     `json:"interrupted"`
+	TranslatedChars int     `json:"translated_chars"`
+	ReusedSegments  int     `json:"reused_segments"`
+	Orphans         int     `json:"orphans"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+type jsonFile struct {
+	Source          string     `json:"source"`
+	Destination     string     `json:"destination,omitempty"`
+	TargetLang      string     `json:"target_lang,omitempty"`
+	Status          FileStatus `json:"status"`
+	Chars           int        `json:"chars"`
+	ReusedSegments  int        `json:"reused_segments"`
+	DurationSeconds float64    `json:"duration_seconds"`
+	SkipReason      string     `json:"skip_reason,omitempty"`
+	Retries         int        `json:"retries"`
+	ErrorCategory   string     `json:"error_category,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+func (r *Report) writeJSON(w io.Writer) error {
+	r.mu.Lock()
+	report := jsonReport{
+		Summary: jsonSummary{
+			Successful:      r.SuccessCount,
+			Skipped:         r.SkippedCount,
+			Failed:          r.FailedCount,
+			Interrupted:     r.InterruptedCount,
+			TranslatedChars: r.TranslatedChars,
+			ReusedSegments:  r.ReusedSegments,
+			Orphans:         r.OrphanCount,
+			DurationSeconds: time.Since(r.started).Seconds(),
+		},
+		Files: make([]jsonFile, 0, len(r.Files)),
+	}
+	for _, f := range r.sortedFiles() {
+		file := jsonFile{
+			Source:          f.Source,
+			Destination:     f.Destination,
+			TargetLang:      f.TargetLang,
+			Status:          f.Status,
+			Chars:           f.Chars,
+			ReusedSegments:  f.ReusedSegments,
+			DurationSeconds: f.Duration.Seconds(),
+			SkipReason:      f.SkipReason,
+			Retries:         f.Retries,
+			ErrorCategory:   f.ErrorCategory,
+		}
+		if f.Err != nil {
+			file.Error = f.Err.Error()
+		}
+		report.Files = append(report.Files, file)
+	}
+	r.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// junitTestSuitesはJUnit XML形式のレポートです。
+// ファイルと言語の組み合わせを1つのテストケースとし、CIのテスト結果の画面で失敗したファイルを確認できるようにします。
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+func (r *Report) writeJUnit(w io.Writer) error {
+	r.mu.Lock()
+	elapsed := time.Since(r.started).Seconds()
+	suite := junitTestSuite{
+		Name: "translate-markdown",
+		Time: fmt.Sprintf("%.3f", elapsed),
+		Properties: []junitProperty{
+			{Name: "translated_chars", Value: fmt.Sprint(r.TranslatedChars)},
+			{Name: "reused_segments", Value: fmt.Sprint(r.ReusedSegments)},
+			{Name: "orphans", Value: fmt.Sprint(r.OrphanCount)},
+		},
+	}
+	for _, f := range r.sortedFiles() {
+		tc := junitTestCase{
+			Name:      f.Source,
+			Classname: "translate-markdown",
+			Time:      fmt.Sprintf("%.3f", f.Duration.Seconds()),
+			SystemOut: fmt.Sprintf("destination=%s chars=%d reused_segments=%d retries=%d", f.Destination, f.Chars, f.ReusedSegments, f.Retries),
+		}
+		if f.TargetLang != "" {
+			tc.Name = fmt.Sprintf("%s -> %s", f.Source, f.Destination)
+			tc.Classname = f.TargetLang
+		}
+		switch f.Status {
+		case StatusFailed:
+			msg := ""
+			if f.Err != nil {
+				msg = f.Err.Error()
+			}
+			tc.Failure = &junitFailure{Message: msg, Type: f.ErrorCategory, Text: msg}
+			suite.Failures++
+		case StatusSkipped:
+			tc.Skipped = &junitSkipped{Message: f.SkipReason}
+			suite.Skipped++
+		case StatusInterrupted:
+			tc.Skipped = &junitSkipped{Message: string(StatusInterrupted)}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	r.mu.Unlock()
+	suite.Tests = len(suite.Cases)
+
+	suites := junitTestSuites{
+		Name:     suite.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
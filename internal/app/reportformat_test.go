@@ -0,0 +1,114 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestReportは成功、スキップ、失敗、中断の結果を1件ずつ含むReportを作成します。
+func newTestReport() *Report {
+	r := NewReport()
+	r.AddFile(FileResult{Source: "docs/b.md", Destination: "out/b.md", TargetLang: "EN", Status: StatusSuccess, Chars: 120, ReusedSegments: 2})
+	r.AddFile(FileResult{Source: "docs/a.md", Destination: "out/a.md", TargetLang: "EN", Status: StatusSkipped, SkipReason: SkipUnchanged})
+	r.AddFile(FileResult{Source: "docs/c.md", Destination: "out/c.md", TargetLang: "EN", Status: StatusFailed, Err: errors.New("boom"), ErrorCategory: CategoryValidation})
+	r.AddFile(FileResult{Source: "docs/d.md", Destination: "out/d.md", TargetLang: "EN", Status: StatusInterrupted, Err: context.Canceled})
+	r.AddOrphan()
+	return r
+}
+
+func TestValidateReportFormat(t *testing.T) {
+	for _, format := range []string{ReportFormatText, ReportFormatJSON, ReportFormatJUnit} {
+		if err := ValidateReportFormat(format); err != nil {
+			t.Errorf("ValidateReportFormat(%q) = %v", format, err)
+		}
+	}
+	for _, format := range []string{"", "xml", "JSON"} {
+		if err := ValidateReportFormat(format); err == nil {
+			t.Errorf("ValidateReportFormat(%q) succeeded, want error", format)
+		}
+		if err := newTestReport().Write(&bytes.Buffer{}, format); err == nil {
+			t.Errorf("Write(%q) succeeded, want error", format)
+		}
+	}
+}
+
+func TestReportWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newTestReport().Write(&buf, ReportFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	var got jsonReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("report is not valid JSON: %v\n%s", err, buf.String())
+	}
+	want := jsonSummary{Successful: 1, Skipped: 1, Failed: 1, Interrupted: 1, TranslatedChars: 120, ReusedSegments: 2, Orphans: 1}
+	got.Summary.DurationSeconds = 0
+	if got.Summary != want {
+		t.Errorf("summary = %+v, want %+v", got.Summary, want)
+	}
+
+	// ファイルはソースのパスの順に並ぶ
+	var sources []string
+	for _, f := range got.Files {
+		sources = append(sources, f.Source)
+	}
+	if strings.Join(sources, ",") != "docs/a.md,docs/b.md,docs/c.md,docs/d.md" {
+		t.Errorf("files = %v", sources)
+	}
+	failed := got.Files[2]
+	if failed.Status != StatusFailed || failed.Error != "boom" || failed.ErrorCategory != CategoryValidation {
+		t.Errorf("failed file = %+v", failed)
+	}
+	if skipped := got.Files[0]; skipped.SkipReason != SkipUnchanged {
+		t.Errorf("skipped file = %+v", skipped)
+	}
+}
+
+func TestReportWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newTestReport().Write(&buf, ReportFormatJUnit); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("report does not start with the XML header:\n%s", buf.String())
+	}
+
+	var got junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("report is not valid XML: %v\n%s", err, buf.String())
+	}
+	if got.Tests != 4 || got.Failures != 1 || got.Skipped != 2 {
+		t.Errorf("testsuites tests=%d failures=%d skipped=%d, want 4, 1, 2", got.Tests, got.Failures, got.Skipped)
+	}
+	if len(got.Suites) != 1 || len(got.Suites[0].Cases) != 4 {
+		t.Fatalf("testsuites = %+v", got)
+	}
+	c := got.Suites[0].Cases[2]
+	if c.Name != "docs/c.md -> out/c.md" || c.Classname != "EN" {
+		t.Errorf("testcase name=%q classname=%q", c.Name, c.Classname)
+	}
+	if c.Failure == nil || c.Failure.Message != "boom" || c.Failure.Type != CategoryValidation {
+		t.Errorf("testcase failure = %+v", c.Failure)
+	}
+	if s := got.Suites[0].Cases[3].Skipped; s == nil || s.Message != string(StatusInterrupted) {
+		t.Errorf("interrupted testcase skipped = %+v", s)
+	}
+}
+
+func TestReportWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newTestReport().Write(&buf, ReportFormatText); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Successful: 1", "Failed:     1", "Interrupted: 1", "- File: docs/c.md", "Error: EN: boom"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text report does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
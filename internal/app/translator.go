@@ -1,126 +1,378 @@
 package app
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 	"unicode/utf8"
 
-	"github.com/bmatcuk/doublestar/v4"
-
 	"github.com/ariela/translate-markdown/internal/deepl"
 	"github.com/ariela/translate-markdown/internal/markdown"
 )
 
+// cacheSaveIntervalは翻訳中にキャッシュを保存する間隔です。
+// 処理が中断された場合でも、完了したファイルのキャッシュが失われないようにします。
+const cacheSaveInterval = 5 * time.Second
+
 // Translatorは翻訳処理のコアロジックを管理します。
 type Translator struct {
-	mdParser    *markdown.Parser
 	deeplClient deepl.Translator
 	cache       *Cache
+	memory      *TranslationMemory
+	projectRoot string
 	Report      *Report
 	force       bool
 	parallel    int
+	// dryRunが有効な場合、APIを呼び出さず、出力先やキャッシュにも書き込まずに翻訳する文字数を見積もります。
+	dryRun   bool
+	Estimate *Estimate
+	// quotaは今回の実行で翻訳するファイルです。nilの場合は全てのファイルを翻訳します。
+	quota *QuotaPlan
+	// glossaryIDsは言語ペア(例: "JA-EN")ごとの用語集IDです。
+	glossaryIDs map[string]string
+	// progressは処理中のファイルなどの進捗を出力する先です。
+	progress io.Writer
+
+	saveMu   sync.Mutex
+	lastSave time.Time
 }
 
 // translationTaskは並列処理のためのタスクを表します。
+// 1つのソースファイルを、targetsに含まれる全ての言語に翻訳します。
 type translationTask struct {
 	sourcePath string
-	destPath   string
-	targetLang string
+	parser     *markdown.Parser
+	targets    []translationTarget
+}
+
+// translationTargetはソースファイルの翻訳先の言語と出力先を表します。
+type translationTarget struct {
+	destPath string
+	opts     deepl.Options
+	// glossaryは使用する用語集の名前です。用語集を使用しない場合は空になります。
+	glossary    string
+	fingerprint string
+	// skipUnchangedOutputがtrueの場合、出力先の内容が変わらなければ書き込みません。
+	skipUnchangedOutput bool
 }
 
 // NewTranslatorは新しいTranslatorインスタンスを作成します。
 func NewTranslator(client deepl.Translator, projectRoot string, force bool, parallel int) (*Translator, error) {
-	parser := markdown.NewParser()
 	cache, err := NewCache(projectRoot)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize cache: %w", err)
 	}
+	memory, err := NewTranslationMemory(projectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize translation memory: %w", err)
+	}
 	return &Translator{
-		mdParser:    parser,
 		deeplClient: client,
 		cache:       cache,
+		memory:      memory,
+		projectRoot: projectRoot,
 		Report:      NewReport(),
+		Estimate:    NewEstimate(),
 		force:       force,
 		parallel:    parallel,
+		progress:    os.Stdout,
+		lastSave:    time.Now(),
 	}, nil
 }
 
+// UseGlossariesは翻訳時に使用する用語集IDを言語ペアごとに設定します。
+func (t *Translator) UseGlossaries(ids map[string]string) {
+	t.glossaryIDs = ids
+}
+
+// UseQuotaPlanは今回の実行で翻訳するファイルを、PlanQuotaで決定したものに制限します。
+func (t *Translator) UseQuotaPlan(plan *QuotaPlan) {
+	t.quota = plan
+}
+
+// EnableDryRunはドライランを有効にします。
+// ドライランではファイルの解析とキャッシュ・翻訳メモリの確認のみを行い、結果をEstimateに記録します。
+func (t *Translator) EnableDryRun() {
+	t.dryRun = true
+}
+
+// SetProgressOutputは進捗の出力先を指定します。既定では標準出力に出力します。
+// 機械可読なレポートを標準出力に出力する場合は、レポートと混ざらないよう標準エラー出力を指定します。
+func (t *Translator) SetProgressOutput(w io.Writer) {
+	t.progress = w
+}
+
 // TranslateJobは単一の翻訳ジョブを処理します。
-func (t *Translator) TranslateJob(job Job, cfg *Config) error {
+// 複数の翻訳先の言語が指定されている場合、ソースファイルの解析は言語間で共有します。
+// ctxがキャンセルされた場合は新しいファイルの翻訳を開始せず、未処理のファイルを中断としてレポートに記録します。
+func (t *Translator) TranslateJob(ctx context.Context, job Job, cfg *Config) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	info, err := os.Stat(job.Source)
 	if err != nil {
 		return fmt.Errorf("source not found: %w", err)
 	}
 
-	targetLang := job.TargetLang
-	if targetLang == "" {
-		targetLang = cfg.TargetLang
+	// 設定の誤りによるエラーは、レポートで他のエラーと区別できるようにする
+	langs, err := targetLangs(job, cfg)
+	if err != nil {
+		return withCategory(CategoryConfig, err)
+	}
+	if err := validateDestination(job.Destination, langs); err != nil {
+		return withCategory(CategoryConfig, err)
+	}
+	if err := validateOrphans(job.Orphans); err != nil {
+		return withCategory(CategoryConfig, err)
+	}
+	filter, err := newFileFilter(job)
+	if err != nil {
+		return withCategory(CategoryConfig, err)
+	}
+
+	parsers, err := newJobParsers(job, cfg)
+	if err != nil {
+		return withCategory(CategoryConfig, err)
+	}
+
+	// 出力先はソースファイルごとに決まるため、ここでは言語ごとの設定のみを作成する
+	targets := make([]translationTarget, 0, len(langs))
+	for _, lang := range langs {
+		opts, err := t.translateOptions(job, cfg, lang)
+		if err != nil {
+			return withCategory(CategoryConfig, err)
+		}
+		var glossary string
+		if opts.SourceLang != "" {
+			glossary, err = glossaryName(cfg.Glossaries, glossaryPair(opts.SourceLang, opts.TargetLang))
+			if err != nil {
+				return withCategory(CategoryConfig, err)
+			}
+		}
+		targets = append(targets, translationTarget{
+			opts:                opts,
+			glossary:            glossary,
+			skipUnchangedOutput: cfg.SkipUnchangedOutput,
+		})
 	}
-	if targetLang == "" {
-		return fmt.Errorf("target_lang is not specified for job or globally")
+
+	if t.dryRun {
+		t.Estimate.beginJob(job)
 	}
 
 	if info.IsDir() {
-		return t.translateDirectory(job, targetLang)
+		return t.translateDirectory(ctx, job, filter, parsers, targets)
 	}
 
 	// 単一ファイルの場合も並列処理の枠組みを使う
-	tasks := []translationTask{{
-		sourcePath: job.Source,
-		destPath:   job.Destination,
-		targetLang: targetLang,
-	}}
-	t.runWorkers(tasks)
+	task, err := newTranslationTask(job, job.Source, parsers, targets)
+	if err != nil {
+		return err
+	}
+	t.runWorkers(ctx, []translationTask{task})
 	return nil
 }
 
-// translateDirectoryはディレクトリ内の全てのMarkdownファイルを再帰的に翻訳します。
-func (t *Translator) translateDirectory(job Job, targetLang string) error {
+// newTranslationTaskはソースファイルの出力先を言語ごとに決定したタスクを作成します。
+// パーサーとキャッシュのフィンガープリントは、ソースファイルの拡張子に応じて決まります。
+func newTranslationTask(job Job, sourcePath string, parsers jobParsers, targets []translationTarget) (translationTask, error) {
+	parser := parsers.forFile(sourcePath)
+	task := translationTask{
+		sourcePath: sourcePath,
+		parser:     parser,
+		targets:    make([]translationTarget, len(targets)),
+	}
+	for i, target := range targets {
+		destPath, err := destinationPath(job, target.opts.TargetLang, sourcePath)
+		if err != nil {
+			return task, err
+		}
+		target.destPath = destPath
+		target.fingerprint = settingsFingerprint(target.opts, target.glossary, parser)
+		task.targets[i] = target
+	}
+	return task, nil
+}
+
+// translateOptionsはジョブとグローバル設定から翻訳リクエストの設定を決定します。
+// ジョブの設定が指定されている場合はグローバル設定より優先されます。
+// targetLangにはtargetLangsで決定した翻訳先の言語を指定します。
+func (t *Translator) translateOptions(job Job, cfg *Config, targetLang string) (deepl.Options, error) {
+	opts := deepl.Options{
+		TargetLang: strings.ToUpper(targetLang),
+		SourceLang: firstNonEmpty(job.SourceLang, cfg.SourceLang),
+		Context:    firstNonEmpty(job.Context, cfg.Context),
+	}
+
+	// DeepLのsource_langは地域を含まない言語コードのみを受け付けるため、"EN-US"などは"EN"に変換する
+	// 用語集はsource_langが指定されている場合のみ使用できる
+	if opts.SourceLang != "" {
+		opts.SourceLang = baseLang(opts.SourceLang)
+		opts.GlossaryID = t.glossaryIDs[glossaryPair(opts.SourceLang, opts.TargetLang)]
+	}
+
+	formality, fallback, err := deepl.ResolveFormality(firstNonEmpty(job.Formality, cfg.Formality), opts.TargetLang)
+	if err != nil {
+		return opts, err
+	}
+	if fallback {
+		slog.Warn("Target language does not support formality, using preference instead",
+			"target_lang", opts.TargetLang, "formality", formality)
+	}
+	opts.Formality = formality
+	return opts, nil
+}
+
+// settingsFingerprintは翻訳結果に影響する設定から、キャッシュで比較するためのハッシュを作成します。
+// 設定が変更された場合、ソースファイルが変更されていなくても再翻訳されます。
+// 用語集はDeepLに問い合わせずに決まるよう、IDではなく内容から決まる名前で区別します。
+func settingsFingerprint(opts deepl.Options, glossary string, parser *markdown.Parser) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "provider=deepl\n")
+	fmt.Fprintf(h, "source_lang=%s\n", opts.SourceLang)
+	fmt.Fprintf(h, "formality=%s\n", opts.Formality)
+	fmt.Fprintf(h, "context=%s\n", opts.Context)
+	fmt.Fprintf(h, "glossary=%s\n", glossary)
+	fmt.Fprintf(h, "unit=%d\n", parser.Mode())
+	fmt.Fprintf(h, "skip=%s\n", parser.Policy())
+	// 既存のキャッシュが無効にならないよう、Frontmatterを翻訳する場合のみ含める
+	if keys := parser.FrontmatterKeys(); len(keys) > 0 {
+		fmt.Fprintf(h, "frontmatter_keys=%s\n", strings.Join(keys, ","))
+	}
+	if protection := parser.Protection(); protection.Enabled() {
+		fmt.Fprintf(h, "protect=%q\n", protection.String())
+	}
+	if mdx, props := parser.MDX(); mdx {
+		fmt.Fprintf(h, "mdx=%s\n", strings.Join(props, ","))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// firstNonEmptyは最初に見つかった空でない文字列を返します。
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// jobParsersはジョブのMarkdownファイルとMDXファイルのパーサーです。
+type jobParsers struct {
+	md  *markdown.Parser
+	mdx *markdown.Parser
+}
+
+// newJobParsersはジョブのパーサーを作成します。
+// ジョブのmdxがtrueの場合は、全てのファイルをMDXとして解析します。
+func newJobParsers(job Job, cfg *Config) (jobParsers, error) {
+	mdx, err := newParser(job, cfg, true)
+	if err != nil {
+		return jobParsers{}, err
+	}
+	if job.MDX {
+		return jobParsers{md: mdx, mdx: mdx}, nil
+	}
+	md, err := newParser(job, cfg, false)
+	if err != nil {
+		return jobParsers{}, err
+	}
+	return jobParsers{md: md, mdx: mdx}, nil
+}
+
+// forFileはソースファイルの拡張子に応じたパーサーを返します。
+func (p jobParsers) forFile(path string) *markdown.Parser {
+	if strings.EqualFold(filepath.Ext(path), ".mdx") {
+		return p.mdx
+	}
+	return p.md
+}
+
+// newParserはジョブとグローバル設定に基づいてMarkdownパーサーを作成します。
+// mdxがtrueの場合はMDXとして解析するパーサーを作成します。
+func newParser(job Job, cfg *Config, mdx bool) (*markdown.Parser, error) {
+	mode, err := markdown.ParseMode(firstNonEmpty(job.TranslationUnit, cfg.TranslationUnit))
+	if err != nil {
+		return nil, err
+	}
+
+	// 除外ノードはグローバル設定とジョブ設定の両方を既定値に追加する
+	policy, err := markdown.DefaultPolicy().Skip(cfg.SkipNodes...)
+	if err == nil {
+		policy, err = policy.Skip(job.SkipNodes...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid skip_nodes: %w", err)
+	}
+
+	// 保護するパターンも同様に、グローバル設定とジョブ設定の両方を使用する
+	protection, err := markdown.Protection{}.Preset(append(append([]string(nil), cfg.Protect...), job.Protect...)...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protect: %w", err)
+	}
+	protection, err = protection.Pattern(append(append([]string(nil), cfg.ProtectPatterns...), job.ProtectPatterns...)...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protect_patterns: %w", err)
+	}
+	opts := []markdown.Option{
+		markdown.WithMode(mode),
+		markdown.WithPolicy(policy),
+		markdown.WithFrontmatterKeys(job.FrontmatterKeys...),
+		markdown.WithProtection(protection),
+	}
+	if mdx {
+		props := markdown.DefaultMDXProps
+		if job.MDXProps != nil {
+			props = job.MDXProps
+		}
+		opts = append(opts, markdown.WithMDX(props...))
+	}
+	return markdown.NewParser(opts...), nil
+}
+
+// translateDirectoryはディレクトリ内の、filterで選択されたファイルを再帰的に翻訳します。
+func (t *Translator) translateDirectory(ctx context.Context, job Job, filter fileFilter, parsers jobParsers, targets []translationTarget) error {
 	var tasks []translationTask
 	walkErr := filepath.WalkDir(job.Source, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			t.Report.AddError(path, err)
 			return nil
 		}
-		if d.IsDir() || !strings.HasSuffix(path, ".md") {
+		// 対象の拡張子でないファイルとincludeに一致しないファイルは、スキップとして記録しない
+		if d.IsDir() || !filter.hasExtension(path) || !filter.included(path) {
 			return nil
 		}
 
 		// 除外チェック
-		for _, pattern := range job.Exclude {
-			match, matchErr := doublestar.Match(pattern, path)
-			if matchErr != nil {
-				t.Report.AddError(path, fmt.Errorf("invalid exclude pattern: %w", matchErr))
-				return nil
-			}
-			if match {
-				fmt.Printf("Skipping excluded file: %s\n", path)
-				t.Report.IncrementSkipped()
-				return nil
+		if filter.excluded(path) {
+			if t.dryRun {
+				t.Estimate.addFile(FileEstimate{Source: path, SkipReason: SkipExcluded})
+			} else {
+				fmt.Fprintf(t.progress, "Skipping excluded file: %s\n", path)
 			}
-		}
-
-		relPath, relErr := filepath.Rel(job.Source, path)
-		if relErr != nil {
-			t.Report.AddError(path, relErr)
+			t.Report.AddFile(FileResult{Source: path, Status: StatusSkipped, SkipReason: SkipExcluded})
 			return nil
 		}
-		destPath := filepath.Join(job.Destination, relPath)
 
-		if mkdirErr := os.MkdirAll(filepath.Dir(destPath), 0755); mkdirErr != nil {
-			t.Report.AddError(path, mkdirErr)
+		task, taskErr := newTranslationTask(job, path, parsers, targets)
+		if taskErr != nil {
+			t.Report.AddError(path, taskErr)
 			return nil
 		}
-
-		tasks = append(tasks, translationTask{
-			sourcePath: path,
-			destPath:   destPath,
-			targetLang: targetLang,
-		})
+		tasks = append(tasks, task)
 		return nil
 	})
 
@@ -128,24 +380,91 @@ func (t *Translator) translateDirectory(job Job, targetLang string) error {
 		return walkErr
 	}
 
-	t.runWorkers(tasks)
-	return nil
+	t.runWorkers(ctx, t.excludeOutputs(job, targets, tasks))
+
+	// 中断された場合は、翻訳済みファイルの整理を行わずに終了する
+	if job.Orphans == "" || ctx.Err() != nil {
+		return nil
+	}
+	return t.handleOrphans(job, targets)
+}
+
+// excludeOutputsは翻訳済みファイルをソースとして扱わないよう、出力先に当たるソースファイルのタスクを除外します。
+// "{dir}/{name}.{lang}.md"のように出力先がソースと同じディレクトリにある場合に、次のファイルを除外します。
+//   - 今回の実行で他のタスクの出力先になるファイル
+//   - キャッシュにこのジョブの出力先として記録されているファイル(ソースが削除された翻訳済みファイルを含む)
+//   - 翻訳先のいずれかの言語で、出力先のテンプレートに一致するファイル
+//
+// ソースファイル自身が出力先になる場合(ソースを上書きする設定)は除外しません。
+func (t *Translator) excludeOutputs(job Job, targets []translationTarget, tasks []translationTask) []translationTask {
+	outputs := make(map[string]bool)
+	for _, task := range tasks {
+		for _, target := range task.targets {
+			outputs[filepath.Clean(target.destPath)] = true
+		}
+	}
+	for _, entry := range t.jobEntries(job, targets) {
+		outputs[filepath.Clean(entry.Destination)] = true
+	}
+	patterns := make([]*regexp.Regexp, 0, len(targets))
+	for _, target := range targets {
+		if pattern := destinationPattern(job, target.opts.TargetLang); pattern != nil {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	isOutput := func(task translationTask) bool {
+		sourcePath := filepath.Clean(task.sourcePath)
+		for _, target := range task.targets {
+			if filepath.Clean(target.destPath) == sourcePath {
+				return false
+			}
+		}
+		if outputs[sourcePath] {
+			return true
+		}
+		for _, pattern := range patterns {
+			if pattern.MatchString(filepath.ToSlash(sourcePath)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	result := tasks[:0]
+	for _, task := range tasks {
+		if !isOutput(task) {
+			result = append(result, task)
+		}
+	}
+	return result
 }
 
 // runWorkersはタスクをワーカーに割り当てて並列実行します。
-func (t *Translator) runWorkers(tasks []translationTask) {
-	taskCh := make(chan translationTask, len(tasks))
+// ctxがキャンセルされた場合、残りのタスクは割り当てずに中断としてレポートに記録します。
+func (t *Translator) runWorkers(ctx context.Context, tasks []translationTask) {
+	taskCh := make(chan translationTask)
 	var wg sync.WaitGroup
 
 	// ワーカーを起動
 	for i := 0; i < t.parallel; i++ {
 		wg.Add(1)
-		go t.worker(&wg, taskCh)
+		go t.worker(ctx, &wg, taskCh)
 	}
 
 	// タスクをチャネルに送信
-	for _, task := range tasks {
-		taskCh <- task
+dispatch:
+	for i, task := range tasks {
+		select {
+		case <-ctx.Done():
+			for _, rest := range tasks[i:] {
+				for _, target := range rest.targets {
+					t.Report.AddFile(target.result(rest.sourcePath, StatusInterrupted))
+				}
+			}
+			break dispatch
+		case taskCh <- task:
+		}
 	}
 	close(taskCh)
 
@@ -154,89 +473,296 @@ func (t *Translator) runWorkers(tasks []translationTask) {
 }
 
 // workerはチャネルからタスクを受け取り、翻訳処理を実行します。
-func (t *Translator) worker(wg *sync.WaitGroup, tasks <-chan translationTask) {
+func (t *Translator) worker(ctx context.Context, wg *sync.WaitGroup, tasks <-chan translationTask) {
 	defer wg.Done()
 	for task := range tasks {
-		if err := t.translateFile(task.sourcePath, task.destPath, task.targetLang); err != nil {
-			t.Report.AddError(task.sourcePath, err)
-		}
+		t.translateFile(ctx, task)
+		t.saveCacheIfDue()
 	}
 }
 
-// translateFileは単一のMarkdownファイルを翻訳します。
-func (t *Translator) translateFile(sourcePath, destPath, targetLang string) error {
+// translateFileは単一のMarkdownファイルを翻訳先の言語ごとに翻訳し、結果をレポートに記録します。
+// ソースファイルの読み込みと解析は、全ての翻訳先で共有します。
+func (t *Translator) translateFile(ctx context.Context, task translationTask) {
+	sourcePath := task.sourcePath
+	fail := func(targets []translationTarget, err error) {
+		for _, target := range targets {
+			t.Report.AddFile(failedResult(target.result(sourcePath, StatusFailed), err))
+		}
+	}
+
 	hash, err := CalculateMD5(sourcePath)
 	if err != nil {
-		return fmt.Errorf("failed to calculate hash for %s: %w", sourcePath, err)
+		fail(task.targets, fmt.Errorf("failed to calculate hash for %s: %w", sourcePath, err))
+		return
 	}
 
-	if !t.force && !t.cache.IsChanged(sourcePath, hash) {
-		fmt.Printf("Skipping unchanged file: %s\n", sourcePath)
-		t.Report.IncrementSkipped()
-		return nil
+	var pending []translationTarget
+	for _, target := range task.targets {
+		if !t.force && !t.cache.IsChanged(target.cacheKey(sourcePath), hash, target.fingerprint) {
+			if t.dryRun {
+				t.Estimate.addFile(FileEstimate{
+					Source:      sourcePath,
+					Destination: target.destPath,
+					TargetLang:  target.opts.TargetLang,
+					SkipReason:  SkipUnchanged,
+				})
+			} else {
+				fmt.Fprintf(t.progress, "Skipping unchanged file: %s [%s]\n", sourcePath, target.opts.TargetLang)
+			}
+			t.Report.AddFile(target.skippedResult(sourcePath, SkipUnchanged))
+			continue
+		}
+		if !t.quota.Allows(target.cacheKey(sourcePath)) {
+			fmt.Fprintf(t.progress, "Skipping file over character budget: %s [%s]\n", sourcePath, target.opts.TargetLang)
+			t.Report.AddFile(target.skippedResult(sourcePath, SkipBudget))
+			continue
+		}
+		pending = append(pending, target)
+	}
+	if len(pending) == 0 {
+		return
 	}
 
-	fmt.Printf("Translating %s -> %s\n", sourcePath, destPath)
-
-	sourceContent, err := os.ReadFile(sourcePath)
+	info, err := os.Stat(sourcePath)
 	if err != nil {
-		return err
+		fail(pending, err)
+		return
+	}
+	src := sourceFile{path: sourcePath, hash: hash, perm: info.Mode().Perm()}
+	src.content, err = os.ReadFile(sourcePath)
+	if err != nil {
+		fail(pending, err)
+		return
 	}
 
-	segments, err := t.mdParser.Parse(sourceContent)
+	src.segments, err = task.parser.Parse(src.content)
 	if err != nil {
 		// parserからの詳細なエラーを返す
-		return fmt.Errorf("failed to parse markdown file %s: %w", sourcePath, err)
+		fail(pending, withCategory(CategoryParse, fmt.Errorf("failed to parse markdown file %s: %w", sourcePath, err)))
+		return
+	}
+	if !t.dryRun {
+		src.structure = task.parser.Structure(src.content)
 	}
 
-	var textsToTranslate []string
-	var charCount int
-	for _, seg := range segments {
+	for i, target := range pending {
+		if err := ctx.Err(); err != nil {
+			fail(pending[i:], err)
+			return
+		}
+
+		// APIへの再送回数を翻訳先ごとに記録する
+		res := target.result(sourcePath, StatusSuccess)
+		stats := &deepl.RequestStats{}
+		start := time.Now()
+		err := t.translateTarget(deepl.WithRequestStats(ctx, stats), task.parser, src, target, &res)
+		res.Duration = time.Since(start)
+		res.Retries = stats.Retries()
+		if err != nil {
+			t.Report.AddFile(failedResult(res, err))
+			continue
+		}
+		// ドライランの結果はEstimateに記録する
+		if !t.dryRun {
+			t.Report.AddFile(res)
+		}
+	}
+}
+
+// failedResultはエラーにより翻訳できなかった結果を返します。
+// 中断により翻訳できなかったファイルは失敗として扱いません。
+func failedResult(res FileResult, err error) FileResult {
+	if errors.Is(err, context.Canceled) {
+		res.Status = StatusInterrupted
+		return res
+	}
+	res.Status = StatusFailed
+	res.Err = err
+	return res
+}
+
+// sourceFileは全ての翻訳先で共有する、読み込み・解析済みのソースファイルです。
+type sourceFile struct {
+	path string
+	hash string
+	// permはソースファイルの権限です。出力先のファイルにも同じ権限を設定します。
+	perm      os.FileMode
+	content   []byte
+	segments  []markdown.Segment
+	structure markdown.Structure
+}
+
+// cacheKeyはソースファイルをこの翻訳先に翻訳した結果のキャッシュキーを返します。
+func (target translationTarget) cacheKey(sourcePath string) CacheKey {
+	return CacheKey{Source: sourcePath, Destination: target.destPath, TargetLang: target.opts.TargetLang}
+}
+
+// resultはソースファイルをこの翻訳先に翻訳した結果を、指定された種類で作成します。
+func (target translationTarget) result(sourcePath string, status FileStatus) FileResult {
+	return FileResult{
+		Source:      sourcePath,
+		Destination: target.destPath,
+		TargetLang:  target.opts.TargetLang,
+		Status:      status,
+	}
+}
+
+// skippedResultはこの翻訳先がスキップされた結果を作成します。
+func (target translationTarget) skippedResult(sourcePath, reason string) FileResult {
+	res := target.result(sourcePath, StatusSkipped)
+	res.SkipReason = reason
+	return res
+}
+
+// memoryOptionsはセグメントの翻訳メモリのキーに使用する設定を返します。
+// 用語集はDeepLのIDの代わりに内容から決まる名前で区別し、ドライランでも同じキーになるようにします。
+// タグの扱いはファイル単位で決まるため、プレースホルダタグを含むセグメントのみキーに含めます。
+// これにより、同じ段落はファイル内の他のセグメントによらず同じキーになります。
+func (target translationTarget) memoryOptions(opts deepl.Options, seg markdown.Segment) deepl.Options {
+	opts.GlossaryID = target.glossary
+	if len(seg.Tags) == 0 {
+		opts.TagHandling = ""
+	}
+	return opts
+}
+
+// translateTargetは解析済みのセグメントを1つの言語に翻訳し、出力先に書き込みます。
+// セグメントは他の言語と共有しているため、コピーしてから翻訳結果を設定します。
+// 翻訳結果の数が翻訳元と一致しない場合や、出力のブロック構造がソースと異なる場合はエラーとし、
+// 出力先、キャッシュ、翻訳メモリのいずれにも記録しません。
+// 送信した文字数と再利用したセグメント数はresに記録します。
+func (t *Translator) translateTarget(ctx context.Context, parser *markdown.Parser, src sourceFile, target translationTarget, res *FileResult) error {
+	sourcePath, destPath := src.path, target.destPath
+	segments := append([]markdown.Segment(nil), src.segments...)
+	opts := target.opts
+	var translatable []int
+	for i, seg := range segments {
 		if seg.IsTranslatable && strings.TrimSpace(seg.Content) != "" {
-			textsToTranslate = append(textsToTranslate, seg.Content)
-			charCount += utf8.RuneCountInString(seg.Content)
+			translatable = append(translatable, i)
+			// プレースホルダタグを含むセグメントはXMLとして翻訳させる
+			if seg.IsXML {
+				opts.TagHandling = "xml"
+			}
 		}
 	}
 
-	if len(textsToTranslate) == 0 {
-		fmt.Printf("No translatable text found in %s, copying file.\n", sourcePath)
-		err = os.WriteFile(destPath, sourceContent, 0644)
-		if err != nil {
-			return err
+	// 翻訳メモリに見つかったセグメントは再利用し、見つからなかったセグメントのみを翻訳する
+	var textsToTranslate []string
+	var missIndexes []int
+	var charCount int
+	for _, i := range translatable {
+		if !t.force {
+			if translation, ok := t.memory.Lookup(segments[i].Content, target.memoryOptions(opts, segments[i])); ok {
+				segments[i].Content = translation
+				continue
+			}
 		}
-		t.cache.Update(sourcePath, hash)
-		t.Report.IncrementSuccess()
+		textsToTranslate = append(textsToTranslate, segments[i].Content)
+		missIndexes = append(missIndexes, i)
+		charCount += utf8.RuneCountInString(segments[i].Content)
+	}
+	res.Chars = charCount
+	res.ReusedSegments = len(translatable) - len(missIndexes)
+
+	if t.dryRun {
+		t.Estimate.addFile(FileEstimate{
+			Source:         sourcePath,
+			Destination:    destPath,
+			TargetLang:     opts.TargetLang,
+			Chars:          charCount,
+			ReusedSegments: len(translatable) - len(missIndexes),
+		})
 		return nil
 	}
 
-	translatedTexts, err := t.deeplClient.Translate(textsToTranslate, targetLang)
-	if err != nil {
+	fmt.Fprintf(t.progress, "Translating %s -> %s\n", sourcePath, destPath)
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
 		return err
 	}
 
-	translatedTextIndex := 0
-	for i, seg := range segments {
-		if seg.IsTranslatable && strings.TrimSpace(seg.Content) != "" {
-			if translatedTextIndex < len(translatedTexts) {
-				segments[i].Content = translatedTexts[translatedTextIndex]
-				translatedTextIndex++
+	if len(translatable) == 0 {
+		fmt.Fprintf(t.progress, "No translatable text found in %s, copying file.\n", sourcePath)
+		if err := t.writeOutput(src, target, src.content); err != nil {
+			return err
+		}
+		t.cache.Update(target.cacheKey(sourcePath), src.hash, target.fingerprint, src.hash)
+		return nil
+	}
+
+	var translatedTexts []string
+	if len(textsToTranslate) > 0 {
+		var err error
+		translatedTexts, err = t.deeplClient.Translate(ctx, textsToTranslate, opts)
+		if err != nil {
+			return err
+		}
+		if len(translatedTexts) != len(textsToTranslate) {
+			return fmt.Errorf("%w: sent %d segments, received %d", deepl.ErrCountMismatch, len(textsToTranslate), len(translatedTexts))
+		}
+
+		for j, i := range missIndexes {
+			// インライン要素や保護した文字列のプレースホルダタグが失われた場合、元に戻せないためエラーにする
+			if missing := segments[i].MissingTags(translatedTexts[j]); len(missing) > 0 {
+				return withCategory(CategoryValidation, fmt.Errorf("translation dropped or broke inline markup %q", missing))
 			}
+			segments[i].Content = alignSpaces(textsToTranslate[j], translatedTexts[j])
 		}
 	}
 
 	reconstructedContent := markdown.Reconstruct(segments)
 
-	if err := os.WriteFile(destPath, []byte(reconstructedContent), 0644); err != nil {
+	// 翻訳結果にMarkdownの記法と解釈される文字列が含まれ、ブロック構造が変わっていないかを確認する
+	if err := src.structure.Compare(parser.Structure([]byte(reconstructedContent))); err != nil {
+		return withCategory(CategoryValidation, fmt.Errorf("translated document structure does not match the source: %w", err))
+	}
+
+	// 出力を確認した後で翻訳メモリに記録し、不正な翻訳結果が再利用されないようにする
+	for j, i := range missIndexes {
+		t.memory.Store(textsToTranslate[j], target.memoryOptions(opts, src.segments[i]), translatedTexts[j])
+	}
+
+	output := []byte(reconstructedContent)
+	if err := t.writeOutput(src, target, output); err != nil {
 		return err
 	}
 
-	t.cache.Update(sourcePath, hash)
-	t.Report.IncrementSuccess()
-	t.Report.AddChars(charCount)
+	t.cache.Update(target.cacheKey(sourcePath), src.hash, target.fingerprint, md5Hex(output))
+	return nil
+}
+
+// writeOutputは出力先にソースファイルと同じ権限でアトミックに書き込みます。
+// 途中で中断や失敗が発生しても、既存の出力先が途中までの内容で上書きされることはありません。
+func (t *Translator) writeOutput(src sourceFile, target translationTarget, data []byte) error {
+	written, err := writeOutput(target.destPath, data, src.perm, target.skipUnchangedOutput)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", target.destPath, err)
+	}
+	if !written {
+		fmt.Fprintf(t.progress, "Output unchanged, not rewritten: %s\n", target.destPath)
+	}
 	return nil
 }
 
-// SaveCacheはメモリ上のキャッシュをファイルに保存します。
+// saveCacheIfDueは前回の保存からcacheSaveIntervalが経過している場合にキャッシュを保存します。
+func (t *Translator) saveCacheIfDue() {
+	t.saveMu.Lock()
+	if time.Since(t.lastSave) < cacheSaveInterval {
+		t.saveMu.Unlock()
+		return
+	}
+	t.lastSave = time.Now()
+	t.saveMu.Unlock()
+
+	if err := t.SaveCache(); err != nil {
+		slog.Warn("Failed to save cache", "error", err)
+	}
+}
+
+// SaveCacheはメモリ上のキャッシュと翻訳メモリをファイルに保存します。
 func (t *Translator) SaveCache() error {
-	return t.cache.Save()
+	if err := t.cache.Save(); err != nil {
+		return err
+	}
+	return t.memory.Save()
 }
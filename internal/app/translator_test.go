@@ -0,0 +1,305 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ariela/translate-markdown/internal/deepl"
+)
+
+// fakeTranslatorは送信されたテキストを記録し、先頭に"[EN] "を付けて返すdeepl.Translatorです。
+type fakeTranslator struct {
+	mu    sync.Mutex
+	calls []fakeCall
+	// translateが設定されている場合は、テキストの翻訳結果をtranslateで作成します。
+	translate func(text string, opts deepl.Options) string
+}
+
+// fakeCallはTranslateの1回の呼び出しです。
+type fakeCall struct {
+	texts []string
+	opts  deepl.Options
+}
+
+func (f *fakeTranslator) Translate(ctx context.Context, texts []string, opts deepl.Options) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fakeCall{texts: append([]string(nil), texts...), opts: opts})
+	translated := make([]string, len(texts))
+	for i, text := range texts {
+		if f.translate != nil {
+			translated[i] = f.translate(text, opts)
+		} else {
+			translated[i] = "[EN] " + text
+		}
+	}
+	return translated, nil
+}
+
+// sentTextsはこれまでにTranslateに送信された全てのテキストを返します。
+func (f *fakeTranslator) sentTexts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var texts []string
+	for _, call := range f.calls {
+		texts = append(texts, call.texts...)
+	}
+	return texts
+}
+
+// writeFilesはdirの下にfiles(キー: 相対パス, 値: 内容)を作成します。
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newTestTranslatorはdirをプロジェクトルートとし、進捗をprogressに出力するTranslatorを作成します。
+func newTestTranslator(t *testing.T, client deepl.Translator, dir string, progress *bytes.Buffer) *Translator {
+	t.Helper()
+	translator, err := NewTranslator(client, dir, false, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	translator.SetProgressOutput(progress)
+	return translator
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// 進捗はSetProgressOutputで指定した出力先に出力され、標準出力のレポートと混ざらない
+func TestTranslatorProgressOutput(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"docs/a.md":     "# Title\n\nHello.\n",
+		"docs/skip.txt": "not markdown\n",
+	})
+	client := &fakeTranslator{}
+	var progress bytes.Buffer
+	translator := newTestTranslator(t, client, dir, &progress)
+	job := Job{Source: filepath.Join(dir, "docs"), Destination: filepath.Join(dir, "out")}
+	cfg := &Config{TargetLang: "EN"}
+
+	if err := translator.TranslateJob(context.Background(), job, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(progress.String(), "Translating "+filepath.Join(dir, "docs", "a.md")) {
+		t.Errorf("progress output = %q, want the translated file", progress.String())
+	}
+	if got := readFile(t, filepath.Join(dir, "out", "a.md")); got != "# [EN] Title\n\n[EN] Hello.\n" {
+		t.Errorf("output = %q", got)
+	}
+
+	// 2回目はキャッシュによりスキップされ、その旨も進捗として出力される
+	progress.Reset()
+	if err := translator.TranslateJob(context.Background(), job, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(progress.String(), "Skipping unchanged file") {
+		t.Errorf("progress output = %q, want the skipped file", progress.String())
+	}
+}
+
+// タグの扱いはファイル単位で決まるが、タグを含まない段落はタグの扱いが異なるファイルの翻訳結果も再利用できる
+func TestTranslationMemoryReuseAcrossFiles(t *testing.T) {
+	// 保護するパターンがあるジョブはXMLとして、ないジョブはプレーンテキストとして翻訳する
+	plain := Job{}
+	xml := Job{ProtectPatterns: []string{`@@\w+@@`}}
+	tests := []struct {
+		name          string
+		first, second Job
+	}{
+		{name: "xml first", first: xml, second: plain},
+		{name: "plain first", first: plain, second: xml},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				"first.md":  "Shared paragraph.\n\nUse @@first@@ here.\n",
+				"second.md": "Shared paragraph.\n\nUse @@second@@ here.\n",
+			})
+			client := &fakeTranslator{}
+			var progress bytes.Buffer
+			translator := newTestTranslator(t, client, dir, &progress)
+			cfg := &Config{TargetLang: "EN"}
+
+			for i, job := range []Job{tt.first, tt.second} {
+				name := []string{"first.md", "second.md"}[i]
+				job.Source = filepath.Join(dir, name)
+				job.Destination = filepath.Join(dir, "out", name)
+				if err := translator.TranslateJob(context.Background(), job, cfg); err != nil {
+					t.Fatal(err)
+				}
+				if len(client.calls) != i+1 {
+					t.Fatalf("%s was not translated", name)
+				}
+			}
+
+			first, second := client.calls[0], client.calls[1]
+			if first.opts.TagHandling == second.opts.TagHandling {
+				t.Fatalf("both files were sent with tag_handling %q", first.opts.TagHandling)
+			}
+			for _, text := range second.texts {
+				if strings.Contains(text, "paragraph") {
+					t.Errorf("shared paragraph %q was translated again with tag_handling %q", text, second.opts.TagHandling)
+				}
+			}
+			firstLine := func(name string) string {
+				line, _, _ := strings.Cut(readFile(t, filepath.Join(dir, "out", name)), "\n")
+				return line
+			}
+			if a, b := firstLine("first.md"), firstLine("second.md"); a != b {
+				t.Errorf("shared paragraph = %q and %q, want the same translation", a, b)
+			}
+		})
+	}
+}
+
+// 出力先がソースと同じディレクトリにある場合、ソースが削除された翻訳済みファイルを次の実行でソースとして翻訳しない
+func TestTranslateJobSkipsPreviousOutputs(t *testing.T) {
+	tests := []struct {
+		name string
+		// removeCacheがtrueの場合は、出力先のテンプレートのみで翻訳済みファイルを判断する
+		removeCache bool
+	}{
+		{name: "recorded in cache"},
+		{name: "matched by template", removeCache: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				"docs/a.md": "A.\n",
+				"docs/b.md": "B.\n",
+			})
+			job := Job{
+				Source:      filepath.Join(dir, "docs"),
+				Destination: "{dir}/{name}.{lang}.md",
+				TargetLangs: []string{"EN-US", "DE"},
+			}
+			run := func() {
+				t.Helper()
+				var progress bytes.Buffer
+				translator := newTestTranslator(t, &fakeTranslator{}, dir, &progress)
+				if err := translator.TranslateJob(context.Background(), job, &Config{}); err != nil {
+					t.Fatal(err)
+				}
+				if err := translator.SaveCache(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			run()
+			if err := os.Remove(filepath.Join(dir, "docs", "b.md")); err != nil {
+				t.Fatal(err)
+			}
+			if tt.removeCache {
+				if err := os.Remove(filepath.Join(dir, cacheFileName)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			run()
+
+			entries, err := os.ReadDir(filepath.Join(dir, "docs"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			if got := strings.Join(names, ","); got != "a.de.md,a.en-us.md,a.md,b.de.md,b.en-us.md" {
+				t.Errorf("files = %s", got)
+			}
+		})
+	}
+}
+
+// 翻訳結果でインライン要素のプレースホルダタグが失われた場合、ファイルを出力せずに検証エラーとする
+func TestTranslateJobRejectsBrokenInlineTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace func(text string) string
+	}{
+		{name: "self-closing paired tag", replace: func(text string) string {
+			return strings.Replace(text, `<x id="0">bold</x>`, `<x id="0"/>`, 1)
+		}},
+		{name: "dropped code span", replace: func(text string) string {
+			return strings.Replace(text, `<x id="1"/>`, "", 1)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"a.md": "Some **bold** and `code`.\n"})
+			client := &fakeTranslator{translate: func(text string, opts deepl.Options) string {
+				return tt.replace(text)
+			}}
+			var progress bytes.Buffer
+			translator := newTestTranslator(t, client, dir, &progress)
+			job := Job{Source: filepath.Join(dir, "a.md"), Destination: filepath.Join(dir, "out.md"), TranslationUnit: "block"}
+			if err := translator.TranslateJob(context.Background(), job, &Config{TargetLang: "EN"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := translator.Report.CountFiles(func(f FileResult) bool { return f.ErrorCategory == CategoryValidation }); got != 1 {
+				t.Errorf("validation failures = %d, want 1 (files %+v)", got, translator.Report.Files)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "out.md")); !os.IsNotExist(err) {
+				t.Errorf("broken translation was written: %v", err)
+			}
+		})
+	}
+}
+
+// 翻訳結果がMarkdownの記法として解釈されてブロック構造が変わった場合、ファイルを出力せず、キャッシュにも記録しない
+func TestTranslateJobRejectsStructureMismatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.md": "# Title\n\nHello.\n"})
+	client := &fakeTranslator{translate: func(text string, opts deepl.Options) string {
+		if text == "Hello." {
+			// 段落の翻訳結果がリストとして解釈される
+			return "- Hello."
+		}
+		return text
+	}}
+	var progress bytes.Buffer
+	translator := newTestTranslator(t, client, dir, &progress)
+	job := Job{Source: filepath.Join(dir, "a.md"), Destination: filepath.Join(dir, "out.md")}
+	cfg := &Config{TargetLang: "EN"}
+	for run := 1; run <= 2; run++ {
+		if err := translator.TranslateJob(context.Background(), job, cfg); err != nil {
+			t.Fatal(err)
+		}
+		if got := translator.Report.CountFiles(func(f FileResult) bool { return f.ErrorCategory == CategoryValidation }); got != run {
+			t.Fatalf("run %d: validation failures = %d, want %d (files %+v)", run, got, run, translator.Report.Files)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.md")); !os.IsNotExist(err) {
+		t.Errorf("translation with a different structure was written: %v", err)
+	}
+	// 失敗したファイルはキャッシュされず、次の実行でも翻訳される
+	if len(client.calls) != 2 {
+		t.Errorf("Translate was called %d times, want 2", len(client.calls))
+	}
+}
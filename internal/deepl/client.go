@@ -2,48 +2,186 @@ package deepl
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 const (
-	apiURLEndpoint = "https://api-free.deepl.com/v2/translate"
-	maxRetries     = 3
+	// FreeAPIURLはDeepL API FreeのベースURLです。
+	FreeAPIURL = "https://api-free.deepl.com"
+	// ProAPIURLはDeepL API ProのベースURLです。
+	ProAPIURL = "https://api.deepl.com"
+
+	translatePath  = "/v2/translate"
 	initialBackoff = 1 * time.Second
+
+	// DefaultMaxRetriesはリクエストが失敗した場合に再送する回数の既定値です。
+	DefaultMaxRetries = 3
+	// DefaultRequestsPerSecondは1秒あたりに送信するリクエスト数の上限の既定値です。
+	DefaultRequestsPerSecond = 5
+
+	// maxTextsPerRequestは1回のリクエストで送信できるテキストの最大数です。
+	maxTextsPerRequest = 50
+	// maxRequestBytesは1回のリクエストボディの最大サイズです。
+	maxRequestBytes = 128 * 1024
 )
 
+// ErrCountMismatchはAPIから返された翻訳結果の数が、送信したテキストの数と異なることを表します。
+var ErrCountMismatch = errors.New("translation count mismatch")
+
 var formalitySupportedLanguages = map[string]bool{
 	"DE": true, "FR": true, "IT": true, "ES": true, "NL": true,
 	"PL": true, "PT-PT": true, "PT-BR": true, "RU": true, "JA": true,
 }
 
+// validFormalitiesはDeepLが受け付けるformalityの値です。
+var validFormalities = map[string]bool{
+	"default": true, "more": true, "less": true, "prefer_more": true, "prefer_less": true,
+}
+
+// SupportsFormalityは翻訳先の言語がformalityの指定に対応しているかを返します。
+func SupportsFormality(targetLang string) bool {
+	return formalitySupportedLanguages[strings.ToUpper(targetLang)]
+}
+
+// ResolveFormalityはformalityの設定値を検証し、翻訳先の言語で使用できる値に変換します。
+// 空の場合は空文字列を返し、formalityを送信せずにDeepLの既定の丁寧さで翻訳します。
+// "more"や"less"が翻訳先の言語で対応していない場合は、エラーにせず"prefer_more"や"prefer_less"に置き換え、
+// fallbackにtrueを返します。
+func ResolveFormality(formality, targetLang string) (resolved string, fallback bool, err error) {
+	if formality == "" {
+		return "", false, nil
+	}
+	formality = strings.ToLower(formality)
+	if !validFormalities[formality] {
+		return "", false, fmt.Errorf("invalid formality %q (expected default, more, less, prefer_more or prefer_less)", formality)
+	}
+	if (formality == "more" || formality == "less") && !SupportsFormality(targetLang) {
+		return "prefer_" + formality, true, nil
+	}
+	return formality, false, nil
+}
+
 // ClientはDeepL APIとの通信を管理します。
 type Client struct {
 	apiKey     string
+	baseURL    string
 	httpClient *http.Client
 	logger     *slog.Logger
+	// batchConcurrencyは1回の翻訳で同時に送信するリクエストの最大数です。
+	batchConcurrency int
+	maxRetries       int
+	limiter          *rateLimiter
+}
+
+// ClientOptionはClientの設定を変更する関数です。
+type ClientOption func(*Client)
+
+// WithBaseURLはAPIのベースURLを指定します。
+// 空の場合はAPIキーから自動で選択されたURLが使用されます。
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		if baseURL != "" {
+			c.baseURL = strings.TrimRight(baseURL, "/")
+		}
+	}
+}
+
+// WithHTTPClientはAPIとの通信に使用するhttp.Clientを指定します。
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithBatchConcurrencyは、テキストがリクエストの上限を超えて複数のリクエストに分割された場合に、
+// 同時に送信するリクエストの最大数を指定します。1以下の場合は順に送信します。
+func WithBatchConcurrency(n int) ClientOption {
+	return func(c *Client) {
+		if n > 0 {
+			c.batchConcurrency = n
+		}
+	}
+}
+
+// WithMaxRetriesは、レート制限、サーバーエラー、通信エラーの場合にリクエストを再送する回数を指定します。
+// 負の値の場合は既定値(DefaultMaxRetries)のままになります。
+func WithMaxRetries(n int) ClientOption {
+	return func(c *Client) {
+		if n >= 0 {
+			c.maxRetries = n
+		}
+	}
+}
+
+// WithRequestsPerSecondは1秒あたりに送信するリクエスト数の上限を指定します。
+// 0以下の場合は既定値(DefaultRequestsPerSecond)のままになります。
+func WithRequestsPerSecond(rate float64) ClientOption {
+	return func(c *Client) {
+		if rate > 0 {
+			c.limiter.requests = newTokenBucket(rate, max(1, rate))
+		}
+	}
+}
+
+// WithCharsPerMinuteは1分あたりに送信する文字数の上限を指定します。
+// 0以下の場合は文字数を制限しません。
+func WithCharsPerMinute(chars int) ClientOption {
+	return func(c *Client) {
+		c.limiter.chars = newTokenBucket(float64(chars)/60, float64(chars))
+	}
 }
 
 // NewClientは新しいDeepLクライアントを作成します。
-func NewClient(apiKey string, logger *slog.Logger) *Client {
-	return &Client{
-		apiKey: apiKey,
+// ベースURLはAPIキーがFree版(":fx"で終わる)かPro版かによって自動で選択されます。
+func NewClient(apiKey string, logger *slog.Logger, opts ...ClientOption) *Client {
+	c := &Client{
+		apiKey:  apiKey,
+		baseURL: DefaultBaseURL(apiKey),
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second,
 		},
-		logger: logger,
+		logger:           logger,
+		batchConcurrency: 1,
+		maxRetries:       DefaultMaxRetries,
+		limiter: &rateLimiter{
+			requests: newTokenBucket(DefaultRequestsPerSecond, DefaultRequestsPerSecond),
+		},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// DefaultBaseURLはAPIキーに対応するベースURLを返します。
+// DeepL API FreeのキーはPro版と区別するため":fx"で終わります。
+func DefaultBaseURL(apiKey string) string {
+	if strings.HasSuffix(apiKey, ":fx") {
+		return FreeAPIURL
+	}
+	return ProAPIURL
 }
 
 // TranslateRequestはAPIへのリクエストボディの構造です。
 type TranslateRequest struct {
-	Text       []string `json:"text"`
-	TargetLang string   `json:"target_lang"`
-	Formality  string   `json:"formality,omitempty"`
+	Text        []string `json:"text"`
+	SourceLang  string   `json:"source_lang,omitempty"`
+	TargetLang  string   `json:"target_lang"`
+	Formality   string   `json:"formality,omitempty"`
+	Context     string   `json:"context,omitempty"`
+	GlossaryID  string   `json:"glossary_id,omitempty"`
+	TagHandling string   `json:"tag_handling,omitempty"`
 }
 
 // TranslateResponseはAPIからの成功レスポンスボディの構造です。
@@ -59,26 +197,149 @@ type ErrorResponse struct {
 }
 
 // TranslateはテキストのスライスをDeepL APIに送信して翻訳します。
-func (c *Client) Translate(texts []string, targetLang string) ([]string, error) {
+// テキストの数やサイズがDeepLのリクエストの上限を超える場合は複数のリクエストに分割し、
+// 結果を元の順に並べて返します。いずれかのリクエストが失敗した場合はエラーを返します。
+// ctxがキャンセルされた場合は、送信中のリクエストとリトライの待機を中断します。
+func (c *Client) Translate(ctx context.Context, texts []string, opts Options) ([]string, error) {
 	if len(texts) == 0 {
 		return []string{}, nil
 	}
 
-	reqBody := TranslateRequest{Text: texts, TargetLang: targetLang}
-	if formalitySupportedLanguages[targetLang] {
-		reqBody.Formality = "more"
+	reqBody := TranslateRequest{
+		SourceLang:  opts.SourceLang,
+		TargetLang:  opts.TargetLang,
+		Formality:   opts.Formality,
+		Context:     opts.Context,
+		GlossaryID:  opts.GlossaryID,
+		TagHandling: opts.TagHandling,
+	}
+	if reqBody.GlossaryID != "" && reqBody.SourceLang == "" {
+		return nil, fmt.Errorf("source_lang is required when using a glossary")
 	}
 
+	batches, err := splitBatches(texts, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if len(batches) == 1 {
+		reqBody.Text = texts
+		return c.translateBatch(ctx, reqBody)
+	}
+	c.logger.Debug("Splitting texts into multiple requests", "texts", len(texts), "requests", len(batches))
+
+	results := make([]string, len(texts))
+	errs := make([]error, len(batches))
+	var failed atomic.Bool
+	batchCh := make(chan int, len(batches))
+	for i := range batches {
+		batchCh <- i
+	}
+	close(batchCh)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(c.batchConcurrency, len(batches)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range batchCh {
+				// 失敗したリクエストがある場合、結果は使用されないため残りは送信しない
+				if failed.Load() {
+					continue
+				}
+				batch := batches[i]
+				req := reqBody
+				req.Text = texts[batch.start:batch.end]
+				translated, err := c.translateBatch(ctx, req)
+				if err != nil {
+					errs[i] = fmt.Errorf("request %d of %d: %w", i+1, len(batches), err)
+					failed.Store(true)
+					continue
+				}
+				copy(results[batch.start:], translated)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// textBatchは1回のリクエストで送信するテキストの範囲[start, end)です。
+type textBatch struct {
+	start, end int
+}
+
+// splitBatchesはテキストを、DeepLのリクエストの上限(テキスト数とボディのサイズ)に収まる範囲に分割します。
+// reqBodyにはテキスト以外の項目を設定したリクエストを指定し、ボディのサイズの計算に使用します。
+func splitBatches(texts []string, reqBody TranslateRequest) ([]textBatch, error) {
+	reqBody.Text = []string{}
+	base, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var batches []textBatch
+	current := textBatch{}
+	size := len(base)
+	for i, text := range texts {
+		encoded, err := json.Marshal(text)
+		if err != nil {
+			return nil, err
+		}
+		// 区切りのカンマを含めたサイズ
+		textSize := len(encoded) + 1
+		if len(base)+textSize > maxRequestBytes {
+			return nil, fmt.Errorf("text %d is too large for a single request (%d bytes, limit %d bytes)", i, textSize, maxRequestBytes)
+		}
+		if i > current.start && (i-current.start >= maxTextsPerRequest || size+textSize > maxRequestBytes) {
+			current.end = i
+			batches = append(batches, current)
+			current = textBatch{start: i}
+			size = len(base)
+		}
+		size += textSize
+	}
+	current.end = len(texts)
+	return append(batches, current), nil
+}
+
+// translateBatchは1回分のリクエストを送信し、レート制限やサーバーエラーの場合はリトライします。
+// リクエストは全てのワーカーで共有するレート制限の範囲で送信します。
+// リトライの待機時間は指数関数的に増加し、揺らぎを加えます。Retry-Afterヘッダーがある場合はその時間以上待機します。
+func (c *Client) translateBatch(ctx context.Context, reqBody TranslateRequest) ([]string, error) {
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, err
 	}
+	chars := 0
+	for _, text := range reqBody.Text {
+		chars += utf8.RuneCountInString(text)
+	}
 
 	var lastErr error
+	var wait time.Duration
 	backoff := initialBackoff
+	endpoint := c.baseURL + translatePath
+
+	stats := requestStatsFrom(ctx)
+	for i := 0; i <= c.maxRetries; i++ {
+		if i > 0 {
+			if err := sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			backoff *= 2
+			stats.addRetry()
+		}
+		if err := c.limiter.wait(ctx, chars); err != nil {
+			return nil, err
+		}
 
-	for i := 0; i < maxRetries; i++ {
-		req, err := http.NewRequest("POST", apiURLEndpoint, bytes.NewBuffer(jsonData))
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(jsonData))
 		if err != nil {
 			return nil, err
 		}
@@ -86,13 +347,16 @@ func (c *Client) Translate(texts []string, targetLang string) ([]string, error)
 		req.Header.Set("Authorization", "DeepL-Auth-Key "+c.apiKey)
 		req.Header.Set("Content-Type", "application/json")
 
-		c.logger.Debug("Sending DeepL API request", "attempt", i+1, "url", apiURLEndpoint, "body", string(jsonData))
+		c.logger.Debug("Sending DeepL API request", "attempt", i+1, "url", endpoint, "body", string(jsonData))
 
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
+			// キャンセルされた場合はリトライしない
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			lastErr = fmt.Errorf("request failed: %w", err)
-			time.Sleep(backoff)
-			backoff *= 2
+			wait = jitter(backoff)
 			continue
 		}
 
@@ -104,7 +368,11 @@ func (c *Client) Translate(texts []string, targetLang string) ([]string, error)
 			}
 			resp.Body.Close()
 
-			var translatedTexts []string
+			// 翻訳結果の数が異なる場合、どのテキストに対応するかわからないため全体をエラーとする
+			if len(translateResp.Translations) != len(reqBody.Text) {
+				return nil, fmt.Errorf("%w: sent %d texts, received %d", ErrCountMismatch, len(reqBody.Text), len(translateResp.Translations))
+			}
+			translatedTexts := make([]string, 0, len(translateResp.Translations))
 			for _, t := range translateResp.Translations {
 				translatedTexts = append(translatedTexts, t.Text)
 			}
@@ -119,26 +387,40 @@ func (c *Client) Translate(texts []string, targetLang string) ([]string, error)
 		resp.Body.Close()
 		c.logger.Debug("Received DeepL API error response", "status", resp.Status, "body", string(body))
 
-		var errorResp ErrorResponse
-		if json.Unmarshal(body, &errorResp) == nil && errorResp.Message != "" {
-			lastErr = fmt.Errorf("API request failed with status %s: %s", resp.Status, errorResp.Message)
-		} else {
-			lastErr = fmt.Errorf("API request failed with status %s", resp.Status)
-		}
+		lastErr = newAPIError(resp, body)
 
 		if resp.StatusCode == 456 { // Quota exceeded
 			return nil, lastErr
 		}
 
 		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
-			c.logger.Warn("Rate limit or server error. Retrying...", "backoff", backoff)
-			time.Sleep(backoff)
-			backoff *= 2
+			wait = max(jitter(backoff), retryAfter(resp.Header, time.Now()))
+			c.logger.Warn("Rate limit or server error. Retrying...", "status", resp.Status, "wait", wait)
+			// レート制限の場合は他のワーカーのリクエストも待機させる
+			if resp.StatusCode == http.StatusTooManyRequests {
+				c.limiter.pause(wait)
+				wait = 0
+			}
 			continue
 		}
 
 		return nil, lastErr
 	}
 
-	return nil, fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
+	return nil, fmt.Errorf("failed after %d retries: %w", c.maxRetries, lastErr)
+}
+
+// sleepは指定された時間待機します。ctxがキャンセルされた場合は待機を中断してエラーを返します。
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
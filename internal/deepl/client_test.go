@@ -0,0 +1,287 @@
+package deepl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeServerは受け取ったテキストの先頭に"T:"を付けて返すDeepL APIのテスト用サーバーです。
+// リクエストごとにテキスト数とボディのサイズが上限以内かを確認します。
+type fakeServer struct {
+	t *testing.T
+	// failTextを含むリクエストには400を返します。
+	failText string
+	// delayはテキストに応じてレスポンスを遅らせる時間を返します。
+	delay func(texts []string) time.Duration
+
+	requests atomic.Int32
+	active   atomic.Int32
+	// maxActiveは同時に処理したリクエスト数の最大値です。
+	maxActive atomic.Int32
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+	active := s.active.Add(1)
+	defer s.active.Add(-1)
+	for {
+		current := s.maxActive.Load()
+		if active <= current || s.maxActive.CompareAndSwap(current, active) {
+			break
+		}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.t.Errorf("failed to read request body: %v", err)
+		return
+	}
+	if len(body) > maxRequestBytes {
+		s.t.Errorf("request body is %d bytes, limit %d", len(body), maxRequestBytes)
+	}
+	var req TranslateRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.t.Errorf("invalid request body: %v", err)
+		return
+	}
+	if len(req.Text) > maxTextsPerRequest {
+		s.t.Errorf("request has %d texts, limit %d", len(req.Text), maxTextsPerRequest)
+	}
+	if s.delay != nil {
+		time.Sleep(s.delay(req.Text))
+	}
+
+	for _, text := range req.Text {
+		if s.failText != "" && text == s.failText {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message": "bad request"}`)
+			return
+		}
+	}
+	var resp TranslateResponse
+	for _, text := range req.Text {
+		resp.Translations = append(resp.Translations, struct {
+			Text string `json:"text"`
+		}{Text: "T:" + text})
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.t.Errorf("failed to write response: %v", err)
+	}
+}
+
+func newTestClient(t *testing.T, handler http.Handler, opts ...ClientOption) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts = append([]ClientOption{WithBaseURL(server.URL), WithMaxRetries(0), WithRequestsPerSecond(1000)}, opts...)
+	return NewClient("test-key", logger, opts...)
+}
+
+func numberedTexts(n int, size int) []string {
+	texts := make([]string, n)
+	for i := range texts {
+		prefix := fmt.Sprintf("text %d ", i)
+		texts[i] = prefix + strings.Repeat("x", max(0, size-len(prefix)))
+	}
+	return texts
+}
+
+func TestSplitBatches(t *testing.T) {
+	large := 40 * 1024
+	tests := []struct {
+		name    string
+		texts   []string
+		want    []textBatch
+		wantErr bool
+	}{
+		{
+			name:  "single batch",
+			texts: numberedTexts(3, 10),
+			want:  []textBatch{{0, 3}},
+		},
+		{
+			name:  "exactly the text limit",
+			texts: numberedTexts(50, 10),
+			want:  []textBatch{{0, 50}},
+		},
+		{
+			name:  "split by text count",
+			texts: numberedTexts(120, 10),
+			want:  []textBatch{{0, 50}, {50, 100}, {100, 120}},
+		},
+		{
+			name:  "split by body size",
+			texts: numberedTexts(7, large),
+			want:  []textBatch{{0, 3}, {3, 6}, {6, 7}},
+		},
+		{
+			name:    "text larger than a request",
+			texts:   append(numberedTexts(1, 10), strings.Repeat("x", maxRequestBytes)),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitBatches(tt.texts, TranslateRequest{TargetLang: "EN"})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("splitBatches() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("splitBatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslateSplitsAndKeepsOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		texts        []string
+		concurrency  int
+		wantRequests int32
+	}{
+		{name: "more than 50 texts", texts: numberedTexts(173, 10), concurrency: 1, wantRequests: 4},
+		{name: "bodies over 128 KiB", texts: numberedTexts(10, 30*1024), concurrency: 1, wantRequests: 3},
+		{name: "concurrent batches", texts: numberedTexts(250, 10), concurrency: 4, wantRequests: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeServer{t: t}
+			if tt.concurrency > 1 {
+				// 後のバッチほど早く返し、完了順が送信順と異なるようにする
+				server.delay = func(texts []string) time.Duration {
+					var i int
+					if _, err := fmt.Sscanf(texts[0], "text %d", &i); err != nil {
+						t.Errorf("unexpected text %q", texts[0])
+					}
+					return time.Duration(len(tt.texts)-i) * 200 * time.Microsecond
+				}
+			}
+			client := newTestClient(t, server, WithBatchConcurrency(tt.concurrency))
+
+			got, err := client.Translate(context.Background(), tt.texts, Options{TargetLang: "EN"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.texts) {
+				t.Fatalf("got %d translations, want %d", len(got), len(tt.texts))
+			}
+			for i, text := range tt.texts {
+				if got[i] != "T:"+text {
+					t.Fatalf("translation %d = %.20q, want %.20q", i, got[i], "T:"+text)
+				}
+			}
+			if n := server.requests.Load(); n != tt.wantRequests {
+				t.Errorf("sent %d requests, want %d", n, tt.wantRequests)
+			}
+			if tt.concurrency > 1 && server.maxActive.Load() < 2 {
+				t.Errorf("requests were not sent concurrently")
+			}
+			if tt.concurrency == 1 && server.maxActive.Load() > 1 {
+				t.Errorf("sent %d requests concurrently, want 1", server.maxActive.Load())
+			}
+		})
+	}
+}
+
+func TestTranslatePartialFailure(t *testing.T) {
+	texts := numberedTexts(150, 10)
+	for _, concurrency := range []int{1, 3} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			// 2番目のバッチのみ失敗する
+			server := &fakeServer{t: t, failText: texts[75]}
+			client := newTestClient(t, server, WithBatchConcurrency(concurrency))
+
+			got, err := client.Translate(context.Background(), texts, Options{TargetLang: "EN"})
+			if err == nil {
+				t.Fatal("Translate() succeeded, want error")
+			}
+			if got != nil {
+				t.Errorf("Translate() returned %d translations with an error", len(got))
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+				t.Errorf("error = %v, want APIError with status 400", err)
+			}
+			if !strings.Contains(err.Error(), "request 2 of 3") {
+				t.Errorf("error = %v, want the failed request number", err)
+			}
+		})
+	}
+}
+
+// 翻訳結果の数が送信したテキストの数と異なる場合はErrCountMismatchを返す
+func TestTranslateCountMismatch(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"translations": [{"text": "only one"}]}`)
+	}))
+	_, err := client.Translate(context.Background(), []string{"a", "b"}, Options{TargetLang: "EN"})
+	if !errors.Is(err, ErrCountMismatch) {
+		t.Errorf("error = %v, want ErrCountMismatch", err)
+	}
+}
+
+func TestResolveFormality(t *testing.T) {
+	tests := []struct {
+		formality, targetLang string
+		want                  string
+		fallback, wantErr     bool
+	}{
+		// 指定されていない場合は送信しない
+		{formality: "", targetLang: "DE", want: ""},
+		{formality: "", targetLang: "EN-US", want: ""},
+		{formality: "more", targetLang: "de", want: "more"},
+		{formality: "LESS", targetLang: "JA", want: "less"},
+		{formality: "prefer_more", targetLang: "EN-US", want: "prefer_more"},
+		{formality: "default", targetLang: "EN-US", want: "default"},
+		// 対応していない言語では"more"や"less"を"prefer_"付きの値に置き換える
+		{formality: "more", targetLang: "EN-US", want: "prefer_more", fallback: true},
+		{formality: "less", targetLang: "zh", want: "prefer_less", fallback: true},
+		{formality: "polite", targetLang: "DE", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, fallback, err := ResolveFormality(tt.formality, tt.targetLang)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ResolveFormality(%q, %q) error = %v, want error %v", tt.formality, tt.targetLang, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want || fallback != tt.fallback {
+			t.Errorf("ResolveFormality(%q, %q) = %q, %v; want %q, %v", tt.formality, tt.targetLang, got, fallback, tt.want, tt.fallback)
+		}
+	}
+}
+
+// formalityが空の場合はリクエストに含めない
+func TestTranslateOmitsEmptyFormality(t *testing.T) {
+	for _, formality := range []string{"", "prefer_less"} {
+		var body string
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			body = string(data)
+			fmt.Fprint(w, `{"translations": [{"text": "T"}]}`)
+		}))
+		if _, err := client.Translate(context.Background(), []string{"a"}, Options{TargetLang: "EN-US", Formality: formality}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.Contains(body, `"formality"`), formality != ""; got != want {
+			t.Errorf("formality %q: request body %s", formality, body)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package deepl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const glossariesPath = "/v2/glossaries"
+
+// GlossaryはDeepLに登録された用語集を表します。
+type Glossary struct {
+	ID         string `json:"glossary_id"`
+	Name       string `json:"name"`
+	Ready      bool   `json:"ready"`
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
+	EntryCount int    `json:"entry_count"`
+}
+
+// CreateGlossaryRequestは用語集を作成するリクエストボディの構造です。
+type CreateGlossaryRequest struct {
+	Name          string `json:"name"`
+	SourceLang    string `json:"source_lang"`
+	TargetLang    string `json:"target_lang"`
+	Entries       string `json:"entries"`
+	EntriesFormat string `json:"entries_format"`
+}
+
+// ListGlossariesはアカウントに登録されている用語集の一覧を取得します。
+func (c *Client) ListGlossaries(ctx context.Context) ([]Glossary, error) {
+	var resp struct {
+		Glossaries []Glossary `json:"glossaries"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, glossariesPath, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list glossaries: %w", err)
+	}
+	return resp.Glossaries, nil
+}
+
+// CreateGlossaryは用語集を作成します。
+// entriesFormatには"tsv"または"csv"を指定します。
+func (c *Client) CreateGlossary(ctx context.Context, req CreateGlossaryRequest) (Glossary, error) {
+	var glossary Glossary
+	if err := c.doJSON(ctx, http.MethodPost, glossariesPath, req, &glossary); err != nil {
+		return Glossary{}, fmt.Errorf("failed to create glossary %s: %w", req.Name, err)
+	}
+	return glossary, nil
+}
+
+// DeleteGlossaryは用語集を削除します。
+func (c *Client) DeleteGlossary(ctx context.Context, id string) error {
+	if err := c.doJSON(ctx, http.MethodDelete, glossariesPath+"/"+id, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete glossary %s: %w", id, err)
+	}
+	return nil
+}
+
+// doJSONはJSONのリクエストを送信し、成功レスポンスをoutにデコードします。
+// 翻訳以外の管理系APIで使用するため、リトライは行いません。
+func (c *Client) doJSON(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	var jsonData []byte
+	if in != nil {
+		var err error
+		jsonData, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(jsonData)
+	}
+
+	if err := c.limiter.wait(ctx, 0); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+c.apiKey)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	c.logger.Debug("Sending DeepL API request", "method", method, "url", req.URL.String(), "body", string(jsonData))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		c.logger.Debug("Received DeepL API error response", "status", resp.Status, "body", string(respBody))
+		return newAPIError(resp, respBody)
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode successful response: %w", err)
+	}
+	return nil
+}
+
+// APIErrorはDeepL APIがエラーレスポンスを返したことを表します。
+type APIError struct {
+	// StatusCodeはHTTPステータスコードです(例: 403、456)。
+	StatusCode int
+	// StatusはHTTPステータスの文字列です(例: "403 Forbidden")。
+	Status string
+	// Messageはエラーレスポンスのボディに含まれるメッセージです。含まれない場合は空になります。
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("API request failed with status %s: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("API request failed with status %s", e.Status)
+}
+
+// newAPIErrorはエラーレスポンスのボディからエラーを作成します。
+func newAPIError(resp *http.Response, body []byte) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Status: resp.Status}
+	var errorResp ErrorResponse
+	if json.Unmarshal(body, &errorResp) == nil {
+		apiErr.Message = errorResp.Message
+	}
+	return apiErr
+}
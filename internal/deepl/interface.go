@@ -1,7 +1,38 @@
 package deepl
 
+import "context"
+
 // Translatorはテキスト翻訳サービスのインターフェースを定義します。
 // これにより、テスト時にAPIクライアントをモックすることができます。
 type Translator interface {
-	Translate(texts []string, targetLang string) ([]string, error)
+	Translate(ctx context.Context, texts []string, opts Options) ([]string, error)
+}
+
+// GlossaryManagerは用語集の管理APIのインターフェースを定義します。
+type GlossaryManager interface {
+	ListGlossaries(ctx context.Context) ([]Glossary, error)
+	CreateGlossary(ctx context.Context, req CreateGlossaryRequest) (Glossary, error)
+	DeleteGlossary(ctx context.Context, id string) error
+}
+
+// UsageReporterはアカウントの使用状況を取得するAPIのインターフェースを定義します。
+type UsageReporter interface {
+	Usage(ctx context.Context) (Usage, error)
+}
+
+// Optionsは翻訳リクエストごとの設定を表します。
+// 空の項目はリクエストに含まれず、DeepLの既定の動作になります。
+type Options struct {
+	// SourceLangは翻訳元の言語です。空の場合、DeepLが言語を自動で検出します。
+	SourceLang string
+	TargetLang string
+	// Formalityは翻訳の丁寧さです("more", "less", "prefer_more"など)。
+	Formality string
+	// Contextは翻訳には含まれないが、翻訳の精度を高めるための補足情報です。
+	Context string
+	// GlossaryIDは翻訳に使用する用語集のIDです。SourceLangの指定が必要です。
+	GlossaryID string
+	// TagHandlingはテキスト内のタグの扱いを指定します("xml"など)。
+	// 空の場合、テキストはプレーンテキストとして翻訳されます。
+	TagHandling string
 }
@@ -0,0 +1,108 @@
+package deepl
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiterはトークンバケット方式で、APIへのリクエスト数と文字数の流量を制限します。
+// Clientごとに1つ作成され、全てのワーカーで共有されます。
+type rateLimiter struct {
+	mu       sync.Mutex
+	requests *tokenBucket
+	chars    *tokenBucket
+	// pausedUntilはレート制限エラーを受け取った後、全てのリクエストを待機させる時刻です。
+	pausedUntil time.Time
+}
+
+// tokenBucketは一定の速度で補充されるトークンのバケットです。
+// nilの場合は制限しません。
+type tokenBucket struct {
+	// rateは1秒あたりに補充されるトークンの数です。
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// newTokenBucketは満杯の状態のバケットを作成します。rateが0以下の場合はnilを返します。
+func newTokenBucket(rate, capacity float64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &tokenBucket{rate: rate, capacity: capacity, tokens: capacity, last: time.Now()}
+}
+
+// reserveはn個のトークンを消費し、トークンが補充されるまでに待機が必要な時間を返します。
+// バケットの容量を超える要求は、バケットが満杯になるまで待機すれば送信できるものとして扱います。
+func (b *tokenBucket) reserve(now time.Time, n float64) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= min(n, b.capacity)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// waitはリクエスト1件とchars文字を送信できるまで待機します。
+// ctxがキャンセルされた場合は待機を中断してエラーを返します。
+func (l *rateLimiter) wait(ctx context.Context, chars int) error {
+	l.mu.Lock()
+	now := time.Now()
+	delay := max(l.requests.reserve(now, 1), l.chars.reserve(now, float64(chars)), l.pausedUntil.Sub(now))
+	l.mu.Unlock()
+
+	for delay > 0 {
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+		// 待機中に他のワーカーがレート制限エラーを受け取った場合は、さらに待機する
+		l.mu.Lock()
+		delay = l.pausedUntil.Sub(time.Now())
+		l.mu.Unlock()
+	}
+	return nil
+}
+
+// pauseは全てのリクエストをdの間待機させます。
+// レート制限エラーを受け取った場合に、他のワーカーが続けてリクエストを送信しないようにします。
+func (l *rateLimiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// jitterはバックオフの待機時間に揺らぎを加え、複数のワーカーのリトライが同時に集中しないようにします。
+// 戻り値はdの半分からdまでの範囲になります。
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
+
+// retryAfterはRetry-Afterヘッダーの値(秒数またはHTTP日付)から待機時間を返します。
+// ヘッダーがない場合や解析できない場合は0を返します。
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,188 @@
+package deepl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketReserve(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	type step struct {
+		after time.Duration // 前のステップからの経過時間
+		n     float64
+		want  time.Duration
+	}
+	tests := []struct {
+		name           string
+		rate, capacity float64
+		steps          []step
+	}{
+		{
+			name: "burst up to capacity",
+			rate: 2, capacity: 2,
+			steps: []step{{0, 1, 0}, {0, 1, 0}, {0, 1, 500 * time.Millisecond}},
+		},
+		{
+			name: "refilled over time",
+			rate: 2, capacity: 2,
+			steps: []step{{0, 2, 0}, {500 * time.Millisecond, 1, 0}, {0, 1, 500 * time.Millisecond}},
+		},
+		{
+			name: "refill is capped at capacity",
+			rate: 10, capacity: 1,
+			steps: []step{{0, 1, 0}, {time.Minute, 1, 0}, {0, 1, 100 * time.Millisecond}},
+		},
+		{
+			name: "waiting requests queue up",
+			rate: 1, capacity: 1,
+			steps: []step{{0, 1, 0}, {0, 1, time.Second}, {0, 1, 2 * time.Second}},
+		},
+		{
+			name: "request larger than capacity waits for a full bucket",
+			rate: 100, capacity: 1000,
+			steps: []step{{0, 500, 0}, {0, 5000, 5 * time.Second}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTokenBucket(tt.rate, tt.capacity)
+			b.last = start
+			now := start
+			for i, s := range tt.steps {
+				now = now.Add(s.after)
+				if got := b.reserve(now, s.n); got != s.want {
+					t.Errorf("step %d: reserve(%v) = %v, want %v", i, s.n, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	for _, rate := range []float64{0, -1} {
+		b := newTokenBucket(rate, 10)
+		if b != nil {
+			t.Fatalf("newTokenBucket(%v) = %+v, want nil", rate, b)
+		}
+		if got := b.reserve(time.Now(), 1e9); got != 0 {
+			t.Errorf("nil bucket reserve = %v, want 0", got)
+		}
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := &rateLimiter{requests: newTokenBucket(20, 1)}
+	ctx := context.Background()
+	start := time.Now()
+	for range 3 {
+		if err := l.wait(ctx, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 1件目は即座に、2件目以降は50msごとに送信される
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests at 20/s took %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestRateLimiterPause(t *testing.T) {
+	l := &rateLimiter{}
+	l.pause(100 * time.Millisecond)
+	// 短い待機時間で、より長い待機を上書きしない
+	l.pause(time.Millisecond)
+
+	start := time.Now()
+	if err := l.wait(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("wait returned after %v, want at least 100ms", elapsed)
+	}
+
+	l.pause(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait with canceled context = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for _, d := range []time.Duration{0, 1, 2, time.Second, time.Minute} {
+		for range 100 {
+			got := jitter(d)
+			if d <= 1 {
+				if got != d {
+					t.Fatalf("jitter(%v) = %v, want %v", d, got, d)
+				}
+				continue
+			}
+			if got < d/2 || got >= d {
+				t.Fatalf("jitter(%v) = %v, want in [%v, %v)", d, got, d/2, d)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "missing", value: "", want: 0},
+		{name: "seconds", value: "3", want: 3 * time.Second},
+		{name: "zero seconds", value: "0", want: 0},
+		{name: "negative seconds", value: "-5", want: 0},
+		{name: "http date", value: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second},
+		{name: "past http date", value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{name: "invalid", value: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.value != "" {
+				header.Set("Retry-After", tt.value)
+			}
+			if got := retryAfter(header, now); got != tt.want {
+				t.Errorf("retryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// 429を受け取った場合は、Retry-Afterで指定された時間だけ待機してからリトライする
+func TestTranslateRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"message": "too many requests"}`)
+			return
+		}
+		fmt.Fprint(w, `{"translations": [{"text": "T:hello"}]}`)
+	})
+	client := newTestClient(t, handler, WithMaxRetries(1))
+
+	start := time.Now()
+	got, err := client.Translate(context.Background(), []string{"hello"}, Options{TargetLang: "EN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "T:hello" {
+		t.Errorf("Translate = %q", got)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("server received %d requests, want 2", n)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least the Retry-After of 1s", elapsed)
+	}
+}
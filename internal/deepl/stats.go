@@ -0,0 +1,41 @@
+package deepl
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// RequestStatsは翻訳リクエストの統計情報を記録します。
+// WithRequestStatsでコンテキストに設定すると、そのコンテキストで実行したTranslateの情報が記録されます。
+// 複数のリクエストから同時に記録できます。
+type RequestStats struct {
+	retries atomic.Int64
+}
+
+type requestStatsKey struct{}
+
+// WithRequestStatsは翻訳リクエストの統計情報をstatsに記録するコンテキストを返します。
+func WithRequestStats(ctx context.Context, stats *RequestStats) context.Context {
+	return context.WithValue(ctx, requestStatsKey{}, stats)
+}
+
+// requestStatsFromはコンテキストに設定された統計情報を返します。設定されていない場合はnilを返します。
+func requestStatsFrom(ctx context.Context) *RequestStats {
+	stats, _ := ctx.Value(requestStatsKey{}).(*RequestStats)
+	return stats
+}
+
+// Retriesはリクエストを再送した回数を返します。
+func (s *RequestStats) Retries() int {
+	if s == nil {
+		return 0
+	}
+	return int(s.retries.Load())
+}
+
+// addRetryは再送した回数を1増やします。sがnilの場合は何もしません。
+func (s *RequestStats) addRetry() {
+	if s != nil {
+		s.retries.Add(1)
+	}
+}
@@ -0,0 +1,32 @@
+package deepl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+const usagePath = "/v2/usage"
+
+// Usageはアカウントの当期の文字数の使用状況を表します。
+type Usage struct {
+	CharacterCount int64 `json:"character_count"`
+	CharacterLimit int64 `json:"character_limit"`
+}
+
+// Remainingは当期に翻訳できる残りの文字数を返します。
+func (u Usage) Remaining() int64 {
+	if u.CharacterCount >= u.CharacterLimit {
+		return 0
+	}
+	return u.CharacterLimit - u.CharacterCount
+}
+
+// Usageはアカウントの文字数の使用状況を取得します。
+func (c *Client) Usage(ctx context.Context) (Usage, error) {
+	var usage Usage
+	if err := c.doJSON(ctx, http.MethodGet, usagePath, nil, &usage); err != nil {
+		return Usage{}, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return usage, nil
+}
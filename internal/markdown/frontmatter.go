@@ -0,0 +1,603 @@
+package markdown
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// frontmatterKindはFrontmatterの形式です。
+type frontmatterKind int
+
+const (
+	// frontmatterNoneはFrontmatterがないことを表します。
+	frontmatterNone frontmatterKind = iota
+	// frontmatterYAMLは---で囲まれたYAMLのFrontmatterです。
+	frontmatterYAML
+	// frontmatterTOMLは+++で囲まれたTOMLのFrontmatterです。
+	frontmatterTOML
+	// frontmatterJSONは{}で囲まれたJSONのFrontmatterです。
+	frontmatterJSON
+)
+
+// Stringは形式の名前を返します。
+func (k frontmatterKind) String() string {
+	switch k {
+	case frontmatterYAML:
+		return "yaml"
+	case frontmatterTOML:
+		return "toml"
+	case frontmatterJSON:
+		return "json"
+	}
+	return "none"
+}
+
+// utf8BOMはUTF-8のバイトオーダーマークです。Frontmatterの前に置かれている場合はFrontmatterに含めます。
+var utf8BOM = []byte("\xEF\xBB\xBF")
+
+// detectFrontmatterはドキュメントの先頭にあるFrontmatterの形式と、終わりの区切り行の改行までのバイト数を返します。
+// goldmarkで解析する前にソースのバイト列から判断するため、先頭の水平線や本文中の---を誤って検出することはありません。
+// 閉じる区切りがない場合や、JSONとして正しくない場合はfrontmatterNoneを返します。
+func detectFrontmatter(source []byte) (frontmatterKind, int) {
+	offset := 0
+	if bytes.HasPrefix(source, utf8BOM) {
+		offset = len(utf8BOM)
+	}
+	body := source[offset:]
+
+	first, rest := cutLine(body)
+	switch strings.TrimRight(string(first), " \t\r\n") {
+	case "---":
+		// YAMLでは...でもドキュメントを終了できる
+		// 先頭の水平線と区別するため、区切りの間はYAMLのマッピングである必要がある
+		if n := closingFence(rest, "---", "..."); n >= 0 && isYAMLMapping(rest[:n]) {
+			return frontmatterYAML, offset + len(first) + n
+		}
+	case "+++":
+		if n := closingFence(rest, "+++"); n >= 0 {
+			return frontmatterTOML, offset + len(first) + n
+		}
+	}
+	if n := jsonFrontmatterLength(body); n > 0 {
+		return frontmatterJSON, offset + n
+	}
+	return frontmatterNone, 0
+}
+
+// isYAMLMappingはFrontmatterの内容(閉じる区切りの行を含む)がYAMLのマッピングとして書かれているかを返します。
+// インデントのない行は、空行、コメント、キー、シーケンスの要素、閉じる区切りのいずれかである必要があります。
+func isYAMLMapping(b []byte) bool {
+	for len(b) > 0 {
+		var line []byte
+		line, b = cutLine(b)
+		s := strings.TrimRight(string(line), " \t\r\n")
+		if len(b) == 0 || s == "" || s[0] == ' ' || s[0] == '\t' || s[0] == '#' {
+			continue
+		}
+		if !yamlKeyPattern.MatchString(s) && !yamlSequenceItemPattern.MatchString(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// cutLineは最初の行(改行を含む)と残りに分割します。
+func cutLine(b []byte) (line, rest []byte) {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[:i+1], b[i+1:]
+	}
+	return b, nil
+}
+
+// closingFenceはfencesのいずれかのみからなる行を探し、その行の改行までのバイト数を返します。
+// 見つからない場合は-1を返します。
+func closingFence(b []byte, fences ...string) int {
+	n := 0
+	for len(b) > 0 {
+		line, rest := cutLine(b)
+		n += len(line)
+		trimmed := strings.TrimRight(string(line), " \t\r\n")
+		for _, fence := range fences {
+			if trimmed == fence {
+				return n
+			}
+		}
+		b = rest
+	}
+	return -1
+}
+
+// jsonFrontmatterLengthはドキュメントが{で始まる場合に、対応する}のある行の改行までのバイト数を返します。
+// }の後に同じ行に他の文字がある場合や、JSONのオブジェクトとして正しくない場合は0を返します。
+func jsonFrontmatterLength(b []byte) int {
+	if len(b) == 0 || b[0] != '{' {
+		return 0
+	}
+	depth := 0
+	inString := false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if !json.Valid(b[:i+1]) {
+				return 0
+			}
+			line, _ := cutLine(b[i+1:])
+			if strings.TrimSpace(string(line)) != "" {
+				return 0
+			}
+			return i + 1 + len(line)
+		}
+	}
+	return 0
+}
+
+// yamlStyleはFrontmatterの値の書式です。
+// 翻訳結果をYAMLとして出力する際に、書式に合わせてクォートとエスケープを行います。
+type yamlStyle int
+
+const (
+	// yamlNoneはFrontmatterの値ではないことを表します。
+	yamlNone yamlStyle = iota
+	// yamlPlainはクォートなしの値です。
+	yamlPlain
+	// yamlFlowPlainはフローシーケンス([a, b])内のクォートなしの値です。
+	yamlFlowPlain
+	// yamlDoubleはダブルクォートで囲まれた値です。
+	yamlDouble
+	// yamlSingleはシングルクォートで囲まれた値です。
+	yamlSingle
+	// yamlBlockはブロックスカラー(|または>)の行です。
+	yamlBlock
+)
+
+// formatは翻訳結果を値の書式に合わせてYAMLの文字列にします。
+// クォートなしの値は、翻訳結果がクォートなしでは別の意味になる場合のみダブルクォートで囲みます。
+func (s yamlStyle) format(value string) string {
+	switch s {
+	case yamlPlain, yamlFlowPlain:
+		if isPlainSafe(value, s == yamlFlowPlain) {
+			return value
+		}
+		return quoteDouble(value)
+	case yamlDouble:
+		return quoteDouble(value)
+	case yamlSingle:
+		return "'" + strings.ReplaceAll(singleLine(value), "'", "''") + "'"
+	case yamlBlock:
+		return singleLine(value)
+	}
+	return value
+}
+
+var doubleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+
+func quoteDouble(value string) string {
+	return `"` + doubleQuoteEscaper.Replace(value) + `"`
+}
+
+// singleLineは改行を空白に置き換えます。
+func singleLine(value string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(value, "\r\n", " "), "\n", " ")
+}
+
+// isPlainSafeは値をクォートなしで出力しても、同じ文字列として解釈されるかを返します。
+func isPlainSafe(value string, flow bool) bool {
+	if value == "" || value != strings.TrimSpace(value) || strings.ContainsAny(value, "\r\n") {
+		return false
+	}
+	if strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(value[0])) {
+		return false
+	}
+	if strings.Contains(value, ": ") || strings.Contains(value, " #") || strings.HasSuffix(value, ":") {
+		return false
+	}
+	if flow && strings.ContainsAny(value, ",[]{}") {
+		return false
+	}
+	return !isYAMLKeyword(value)
+}
+
+// isYAMLKeywordはクォートなしの値が真偽値やnullとして解釈されるかを返します。
+func isYAMLKeyword(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return true
+	}
+	return false
+}
+
+// yamlKeyPatternはインデントのないマッピングのキーに一致します。
+// 日本語のキーや、空白で区切った3語までのキー("Last Modified"など)も認めます。
+// 句読点を含む場合や4語以上の場合は、コロンを含む本文の文章とみなして一致しません。
+var yamlKeyPattern = regexp.MustCompile(`^("[^"]*"|'[^']*'|[\p{L}\p{N}_][\p{L}\p{N}_.-]*(?: [\p{L}\p{N}_][\p{L}\p{N}_.-]*){0,2})[ \t]*:(?:[ \t]+|$)`)
+
+// yamlBlockIndicatorPatternはブロックスカラーの開始(|、>と、チョンピング・インデントの指定)に一致します。
+var yamlBlockIndicatorPattern = regexp.MustCompile(`^([|>])[+-]?[1-9]?[+-]?[ \t]*(?:#.*)?$`)
+
+// yamlSequenceItemPatternはブロックシーケンスの要素の行に一致します。
+var yamlSequenceItemPattern = regexp.MustCompile(`^[ \t]*-[ \t]+`)
+
+// frontmatterSegmentsはFrontmatterをセグメントに分割します。
+// YAMLの場合は指定されたキーの値(文字列、または文字列のシーケンス)のみを翻訳対象とし、
+// キーの順序、コメント、クォートなどの残りの部分は元のまま出力します。
+// TOMLとJSONのFrontmatterは翻訳しません。
+func (p *Parser) frontmatterSegments(kind frontmatterKind, frontmatter string) []Segment {
+	if kind != frontmatterYAML || len(p.frontmatterKeys) == 0 {
+		return []Segment{{Content: frontmatter}}
+	}
+	s := &frontmatterSplitter{parser: p}
+	s.split(frontmatter)
+	return s.flush()
+}
+
+// frontmatterSplitterはFrontmatterを1行ずつ解析し、セグメントを作成します。
+type frontmatterSplitter struct {
+	parser   *Parser
+	segments []Segment
+	// verbatimは翻訳しない部分のうち、まだセグメントにしていないものです。
+	verbatim strings.Builder
+}
+
+// valueCutは行の中の翻訳対象の値の位置です。
+type valueCut struct {
+	start, stop int
+	value       string
+	style       yamlStyle
+}
+
+// frontmatterStateは直前のキーの値がどのように続いているかを表します。
+type frontmatterState int
+
+const (
+	// stateOtherは翻訳対象でないキーの値の中にいることを表します。
+	stateOther frontmatterState = iota
+	// stateSequenceは翻訳対象のキーの値が次の行以降に続くこと(ブロックシーケンス)を表します。
+	stateSequence
+	// stateLiteralは翻訳対象のキーのリテラルスカラー(|)の中にいることを表します。
+	stateLiteral
+	// stateFoldedは翻訳対象のキーの折り畳みスカラー(>)の中にいることを表します。
+	stateFolded
+)
+
+func (s *frontmatterSplitter) split(frontmatter string) {
+	state := stateOther
+	// foldedは折り畳みスカラーの、まだ出力していない連続する行です。
+	var folded []string
+	foldIndent := ""
+	flushFolded := func() {
+		if len(folded) == 0 {
+			return
+		}
+		s.emit(foldIndent, "", valueCut{value: strings.Join(folded, " "), style: yamlBlock})
+		s.verbatim.WriteString("\n")
+		folded = nil
+	}
+
+	lines := strings.SplitAfter(frontmatter, "\n")
+	// skipは複数行にわたる値として出力済みの、後続の行数です。
+	skip := 0
+	for i, raw := range lines {
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if raw == "" {
+			continue
+		}
+		line := strings.TrimRight(raw, "\r\n")
+		indented := line != "" && (line[0] == ' ' || line[0] == '\t')
+		blank := strings.TrimSpace(line) == ""
+
+		// インデントされた行と空行は直前のキーの値の続き
+		if state == stateLiteral || state == stateFolded {
+			if blank || indented {
+				content := strings.TrimLeft(line, " \t")
+				indent := line[:len(line)-len(content)]
+				if state == stateFolded && !blank && (len(folded) == 0 || indent == foldIndent) {
+					if len(folded) == 0 {
+						foldIndent = indent
+					}
+					folded = append(folded, strings.TrimRight(content, " \t"))
+					continue
+				}
+				flushFolded()
+				if blank {
+					s.verbatim.WriteString(raw)
+					continue
+				}
+				trimmed := strings.TrimRight(content, " \t")
+				s.emitLine(raw, []valueCut{{start: len(indent), stop: len(indent) + len(trimmed), value: trimmed, style: yamlBlock}})
+				continue
+			}
+			flushFolded()
+			state = stateOther
+		}
+
+		if state == stateSequence && (blank || indented || yamlSequenceItemPattern.MatchString(line) || strings.HasPrefix(line, "#")) {
+			if loc := yamlSequenceItemPattern.FindStringIndex(line); loc != nil {
+				s.emitLine(raw, s.scalarCuts(line, loc[1], false))
+			} else {
+				s.verbatim.WriteString(raw)
+			}
+			continue
+		}
+
+		state = stateOther
+		m := yamlKeyPattern.FindStringSubmatchIndex(line)
+		if m == nil {
+			// 翻訳対象でないキーの値の続き、コメント、フェンスなど
+			s.verbatim.WriteString(raw)
+			continue
+		}
+
+		key := strings.Trim(line[m[2]:m[3]], `"'`)
+		if !s.parser.translatesFrontmatterKey(key) {
+			s.verbatim.WriteString(raw)
+			continue
+		}
+
+		pos := m[1]
+		rest := strings.TrimSpace(line[pos:])
+		switch {
+		case rest == "" || strings.HasPrefix(rest, "#"):
+			state = stateSequence
+			s.verbatim.WriteString(raw)
+		case yamlBlockIndicatorPattern.MatchString(rest):
+			state = stateLiteral
+			if rest[0] == '>' {
+				state = stateFolded
+			}
+			s.verbatim.WriteString(raw)
+		case continues(lines, i):
+			cut, n, ok := plainMultilineCut(lines, i, pos)
+			if !ok {
+				// クォートされた値などは、行ごとに翻訳すると意味が変わるため翻訳しない
+				s.verbatim.WriteString(raw)
+				continue
+			}
+			// 複数行にわたるクォートなしの値は、1行にまとめて翻訳する
+			value := strings.Join(lines[i:i+n+1], "")
+			end := len(strings.TrimRight(value, "\r\n"))
+			s.emit(line[:cut.start], value[cut.start:end], cut)
+			s.verbatim.WriteString(value[end:])
+			skip = n
+		case rest[0] == '[':
+			s.emitLine(raw, s.flowSequenceCuts(line, strings.Index(line[pos:], "[")+pos))
+		default:
+			s.emitLine(raw, s.scalarCuts(line, pos, false))
+		}
+	}
+	flushFolded()
+}
+
+// continuesはlines[i]の値が、インデントされた次の行に続くかを返します。
+// 空行を挟んでインデントされた行が続く場合も、値の続きとみなします。
+func continues(lines []string, i int) bool {
+	for _, next := range lines[i+1:] {
+		if strings.TrimSpace(next) != "" {
+			return next[0] == ' ' || next[0] == '\t'
+		}
+	}
+	return false
+}
+
+// plainMultilineCutはlines[i]のpos以降から始まり、インデントされた次の行以降に続くクォートなしの値を解析します。
+// YAMLでは行の間の改行は空白として扱われるため、各行を空白で連結した値と、値が続く後続の行数を返します。
+// クォートされた値や、空行やコメントを挟む値など、1行にまとめると意味が変わる場合はokにfalseを返します。
+func plainMultilineCut(lines []string, i, pos int) (cut valueCut, n int, ok bool) {
+	first := strings.TrimRight(lines[i], "\r\n")
+	for pos < len(first) && (first[pos] == ' ' || first[pos] == '\t') {
+		pos++
+	}
+	head, ok := parseScalar(first, pos, false)
+	if !ok || head.style != yamlPlain || strings.TrimSpace(first[head.stop:]) != "" {
+		return cut, 0, false
+	}
+
+	parts := []string{head.value}
+	j := i + 1
+	for ; j < len(lines); j++ {
+		line := strings.TrimRight(lines[j], "\r\n")
+		if strings.TrimSpace(line) == "" || (line[0] != ' ' && line[0] != '\t') {
+			break
+		}
+		content := strings.TrimSpace(line)
+		if strings.HasPrefix(content, "#") || strings.Contains(content, " #") || strings.Contains(content, ": ") || strings.HasSuffix(content, ":") {
+			return cut, 0, false
+		}
+		parts = append(parts, content)
+	}
+	// 空行の後に値が続く場合、空行は改行として扱われる
+	if continues(lines, j-1) {
+		return cut, 0, false
+	}
+	return valueCut{start: head.start, value: strings.Join(parts, " "), style: yamlPlain}, j - i - 1, true
+}
+
+// scalarCutsはlineのpos以降にある値が翻訳できる場合に、その位置を返します。
+// 値の後にはコメントのみを置くことができます。
+func (s *frontmatterSplitter) scalarCuts(line string, pos int, flow bool) []valueCut {
+	for pos < len(line) && (line[pos] == ' ' || line[pos] == '\t') {
+		pos++
+	}
+	cut, ok := parseScalar(line, pos, flow)
+	if !ok {
+		return nil
+	}
+	rest := strings.TrimSpace(line[cut.stop:])
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return nil
+	}
+	return []valueCut{cut}
+}
+
+// flowSequenceCutsはlineのpos(開始の[)から始まる1行のフローシーケンスの各要素の位置を返します。
+// 複数行にわたるものや、入れ子になったものは翻訳しません。
+func (s *frontmatterSplitter) flowSequenceCuts(line string, pos int) []valueCut {
+	var cuts []valueCut
+	pos++
+	skipSpaces := func() {
+		for pos < len(line) && (line[pos] == ' ' || line[pos] == '\t') {
+			pos++
+		}
+	}
+	for {
+		skipSpaces()
+		if pos < len(line) && line[pos] == ']' {
+			break
+		}
+		cut, ok := parseScalar(line, pos, true)
+		if !ok {
+			return nil
+		}
+		cuts = append(cuts, cut)
+		pos = cut.stop
+		skipSpaces()
+		if pos < len(line) && line[pos] == ',' {
+			pos++
+			continue
+		}
+		if pos < len(line) && line[pos] == ']' {
+			break
+		}
+		return nil
+	}
+	rest := strings.TrimSpace(line[pos+1:])
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return nil
+	}
+	return cuts
+}
+
+// parseScalarはlineのposから始まるスカラーを解析します。
+// アンカー、エイリアス、タグなど、翻訳すると意味が変わる値の場合はokにfalseを返します。
+func parseScalar(line string, pos int, flow bool) (cut valueCut, ok bool) {
+	if pos >= len(line) {
+		return cut, false
+	}
+	cut.start = pos
+	switch line[pos] {
+	case '"':
+		end := pos + 1
+		for end < len(line) && line[end] != '"' {
+			if line[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(line) {
+			return cut, false
+		}
+		value, err := strconv.Unquote(line[pos : end+1])
+		if err != nil {
+			return cut, false
+		}
+		cut.stop, cut.value, cut.style = end+1, value, yamlDouble
+	case '\'':
+		end := pos + 1
+		for end < len(line) {
+			if line[end] == '\'' {
+				if end+1 < len(line) && line[end+1] == '\'' {
+					end += 2
+					continue
+				}
+				break
+			}
+			end++
+		}
+		if end >= len(line) {
+			return cut, false
+		}
+		cut.stop, cut.value, cut.style = end+1, strings.ReplaceAll(line[pos+1:end], "''", "'"), yamlSingle
+	default:
+		if strings.ContainsRune("&*!|>{[%@`#", rune(line[pos])) {
+			return cut, false
+		}
+		end := pos
+		for end < len(line) {
+			c := line[end]
+			if flow && (c == ',' || c == ']') {
+				break
+			}
+			if c == '#' && end > pos && (line[end-1] == ' ' || line[end-1] == '\t') {
+				break
+			}
+			end++
+		}
+		value := strings.TrimRight(line[pos:end], " \t")
+		if strings.Contains(value, ": ") || strings.HasSuffix(value, ":") || isYAMLKeyword(value) {
+			return cut, false
+		}
+		cut.stop, cut.value, cut.style = pos+len(value), value, yamlPlain
+		if flow {
+			cut.style = yamlFlowPlain
+		}
+	}
+	return cut, true
+}
+
+// emitLineは1行を、翻訳対象の値とそれ以外の部分に分けて追加します。
+func (s *frontmatterSplitter) emitLine(line string, cuts []valueCut) {
+	last := 0
+	for _, cut := range cuts {
+		s.emit(line[last:cut.start], line[cut.start:cut.stop], cut)
+		last = cut.stop
+	}
+	s.verbatim.WriteString(line[last:])
+}
+
+// emitはprefixに続けて値を追加します。
+// 文字を含まない値(数値など)は翻訳せず、originalをそのまま出力します。
+func (s *frontmatterSplitter) emit(prefix, original string, cut valueCut) {
+	s.verbatim.WriteString(prefix)
+	if !strings.ContainsFunc(cut.value, unicode.IsLetter) {
+		if original == "" {
+			original = cut.value
+		}
+		s.verbatim.WriteString(original)
+		return
+	}
+	s.flushVerbatim()
+	seg := Segment{Content: cut.value, IsTranslatable: true, yaml: cut.style}
+	// 翻訳リクエストがXMLとして送信される場合は、値もエスケープする
+	if s.parser.emitsXML() {
+		seg.Content = html.EscapeString(seg.Content)
+		seg.IsXML = true
+	}
+	s.segments = append(s.segments, seg)
+}
+
+func (s *frontmatterSplitter) flushVerbatim() {
+	if s.verbatim.Len() > 0 {
+		s.segments = append(s.segments, Segment{Content: s.verbatim.String()})
+		s.verbatim.Reset()
+	}
+}
+
+func (s *frontmatterSplitter) flush() []Segment {
+	s.flushVerbatim()
+	return s.segments
+}
@@ -0,0 +1,295 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+	"testing"
+)
+
+func TestDetectFrontmatter(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		kind   frontmatterKind
+		body   string
+	}{
+		{
+			name:   "ascii keys",
+			source: "---\ntitle: x\n---\n本文\n",
+			kind:   frontmatterYAML,
+			body:   "本文\n",
+		},
+		{
+			name:   "non-ascii key",
+			source: "---\nタイトル: テスト\n---\n本文\n",
+			kind:   frontmatterYAML,
+			body:   "本文\n",
+		},
+		{
+			name:   "key with spaces",
+			source: "---\nLast Modified: 2024\ntitle: x\n---\n",
+			kind:   frontmatterYAML,
+			body:   "",
+		},
+		{
+			name:   "quoted key",
+			source: "---\n\"a: b\": c\n---\n",
+			kind:   frontmatterYAML,
+			body:   "",
+		},
+		{
+			name:   "sequence and comment",
+			source: "---\n# comment\ntags:\n- a\n- b\n---\n",
+			kind:   frontmatterYAML,
+			body:   "",
+		},
+		{
+			name:   "prose with a colon",
+			source: "---\nThe following settings are required: a and b\n---\n",
+			kind:   frontmatterNone,
+			body:   "---\nThe following settings are required: a and b\n---\n",
+		},
+		{
+			name:   "prose with punctuation before a colon",
+			source: "---\nFirst, run: make\n---\n",
+			kind:   frontmatterNone,
+			body:   "---\nFirst, run: make\n---\n",
+		},
+		{
+			name:   "japanese prose with a colon",
+			source: "---\n次の設定が必要です。例: a\n---\n",
+			kind:   frontmatterNone,
+			body:   "---\n次の設定が必要です。例: a\n---\n",
+		},
+		{
+			name:   "thematic break and setext heading",
+			source: "---\nSome text\n---\n",
+			kind:   frontmatterNone,
+			body:   "---\nSome text\n---\n",
+		},
+		{
+			name:   "toml",
+			source: "+++\ntitle = \"x\"\n+++\n本文\n",
+			kind:   frontmatterTOML,
+			body:   "本文\n",
+		},
+		{
+			name:   "json",
+			source: "{\n  \"title\": \"x\"\n}\n本文\n",
+			kind:   frontmatterJSON,
+			body:   "本文\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, n := detectFrontmatter([]byte(tt.source))
+			if kind != tt.kind {
+				t.Fatalf("kind = %v, want %v", kind, tt.kind)
+			}
+			if body := tt.source[n:]; body != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+// Frontmatterのキーと値は、キーの文字種によらず翻訳対象にならない
+func TestParseFrontmatterNotTranslated(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{name: "non-ascii key", source: "---\nタイトル: テスト\n---\n本文\n", want: []string{"本文"}},
+		{name: "key with spaces", source: "---\nLast Modified: 2024\ntitle: x\n---\n", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, mode := range []Mode{ModeText, ModeBlock} {
+				segments, err := NewParser(WithMode(mode)).Parse([]byte(tt.source))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, seg := range segments {
+					if seg.IsTranslatable {
+						got = append(got, strings.TrimSpace(seg.Content))
+					}
+				}
+				if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+					t.Errorf("mode %d: translatable = %q, want %q", mode, got, tt.want)
+				}
+				if out := Reconstruct(segments); out != tt.source {
+					t.Errorf("mode %d: Reconstruct = %q, want %q", mode, out, tt.source)
+				}
+			}
+		})
+	}
+}
+
+// XMLとして翻訳リクエストを送信するパーサーでは、Frontmatterの値もエスケープし、翻訳結果の文字参照を元に戻す
+func TestFrontmatterValueXMLEscape(t *testing.T) {
+	protection, err := Protection{}.Preset("hugo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := "---\ntitle: A & B <c>\n---\n\nText {{< ref \"x.md\" >}} here.\n"
+	tests := []struct {
+		name  string
+		opts  []Option
+		isXML bool
+	}{
+		{name: "text", opts: nil, isXML: false},
+		{name: "block", opts: []Option{WithMode(ModeBlock)}, isXML: true},
+		{name: "protection", opts: []Option{WithProtection(protection)}, isXML: true},
+		{name: "mdx", opts: []Option{WithMDX(DefaultMDXProps...)}, isXML: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithFrontmatterKeys("title")}, tt.opts...)
+			segments, err := NewParser(opts...).Parse([]byte(source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var title *Segment
+			for i := range segments {
+				if segments[i].yaml != yamlNone {
+					title = &segments[i]
+				}
+				// 本文とFrontmatterの値は同じリクエストで送信されるため、XMLかどうかが一致する必要がある
+				if segments[i].IsTranslatable && segments[i].IsXML != tt.isXML {
+					t.Errorf("segment %q IsXML = %v, want %v", segments[i].Content, segments[i].IsXML, tt.isXML)
+				}
+			}
+			if title == nil {
+				t.Fatal("title value is not a segment")
+			}
+			want := "A & B <c>"
+			if tt.isXML {
+				want = "A &amp; B &lt;c&gt;"
+			}
+			if title.Content != want {
+				t.Errorf("title Content = %q, want %q", title.Content, want)
+			}
+			if got := Reconstruct(segments); got != source {
+				t.Errorf("Reconstruct = %q, want %q", got, source)
+			}
+
+			// DeepLは文字参照のまま翻訳結果を返す
+			title.Content = strings.ReplaceAll(title.Content, "B", "D")
+			if got := title.Markdown(); got != "A & D <c>" {
+				t.Errorf("translated title = %q", got)
+			}
+		})
+	}
+}
+
+// 翻訳後もFrontmatterのキーの順序、コメント、値のクォートの書式は元のまま保たれる
+func TestFrontmatterTranslationKeepsFormat(t *testing.T) {
+	tests := []struct {
+		name         string
+		source       string
+		translations map[string]string
+		want         string
+	}{
+		{
+			name: "key order and comments",
+			source: "---\n# comment\ndate: 2024-01-01\ntitle: Hello world # trailing\ndraft: false\n" +
+				"description: \"A \\\"quoted\\\" text\"\nsummary: 'It''s here'\n---\n",
+			translations: map[string]string{
+				"Hello world":     "こんにちは",
+				`A "quoted" text`: `"引用" です`,
+				"It's here":       "ここ's",
+			},
+			want: "---\n# comment\ndate: 2024-01-01\ntitle: こんにちは # trailing\ndraft: false\n" +
+				"description: \"\\\"引用\\\" です\"\nsummary: 'ここ''s'\n---\n",
+		},
+		{
+			name:         "plain value quoted only when needed",
+			source:       "---\ntitle: Hello\ndescription: World\n---\n",
+			translations: map[string]string{"Hello": "注意: 挨拶", "World": "世界"},
+			want:         "---\ntitle: \"注意: 挨拶\"\ndescription: 世界\n---\n",
+		},
+		{
+			name:         "flow sequence",
+			source:       "---\ntags: [one, 'two', \"three\"] # list\n---\n",
+			translations: map[string]string{"one": "一, 二", "two": "二", "three": "三"},
+			want:         "---\ntags: [\"一, 二\", '二', \"三\"] # list\n---\n",
+		},
+		{
+			name:         "block sequence",
+			source:       "---\ntags:\n  # first\n  - one\n  - 'two'\nweight: 3\n---\n",
+			translations: map[string]string{"one": "一", "two": "二"},
+			want:         "---\ntags:\n  # first\n  - 一\n  - '二'\nweight: 3\n---\n",
+		},
+		{
+			// YAMLでは行の間の改行は空白になるため、連結した値を翻訳して1行で出力する
+			name:         "plain multi-line value",
+			source:       "---\ndescription: A long\n  description text\ntitle: Hi\n---\n",
+			translations: map[string]string{"A long description text": "長い説明", "Hi": "やあ"},
+			want:         "---\ndescription: 長い説明\ntitle: やあ\n---\n",
+		},
+		{
+			name:         "plain multi-line value with CRLF",
+			source:       "---\r\ndescription: A long\r\n  text\r\n---\r\n",
+			translations: map[string]string{"A long text": "長い文"},
+			want:         "---\r\ndescription: 長い文\r\n---\r\n",
+		},
+		{
+			// 空行は改行として扱われ、1行にまとめると意味が変わるため翻訳しない
+			name:         "multi-line value with a blank line",
+			source:       "---\ndescription: first\n\n  second\ntitle: Hi\n---\n",
+			translations: map[string]string{"Hi": "やあ"},
+			want:         "---\ndescription: first\n\n  second\ntitle: やあ\n---\n",
+		},
+		{
+			name:         "multi-line value with a comment",
+			source:       "---\ndescription: first\n  # comment\n  second\ntitle: Hi\n---\n",
+			translations: map[string]string{"Hi": "やあ"},
+			want:         "---\ndescription: first\n  # comment\n  second\ntitle: やあ\n---\n",
+		},
+		{
+			name:         "quoted multi-line value",
+			source:       "---\ndescription: \"first\n  second\"\ntitle: Hi\n---\n",
+			translations: map[string]string{"Hi": "やあ"},
+			want:         "---\ndescription: \"first\n  second\"\ntitle: やあ\n---\n",
+		},
+		{
+			name:         "newline in a single-quoted translation",
+			source:       "---\ntitle: 'Hello'\n---\n",
+			translations: map[string]string{"Hello": "こんにちは\n世界"},
+			want:         "---\ntitle: 'こんにちは 世界'\n---\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, mode := range []Mode{ModeText, ModeBlock} {
+				segments, err := NewParser(WithMode(mode), WithFrontmatterKeys("title", "description", "summary", "tags")).Parse([]byte(tt.source))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i, seg := range segments {
+					if !seg.IsTranslatable {
+						continue
+					}
+					value := seg.Content
+					if seg.IsXML {
+						value = html.UnescapeString(value)
+					}
+					translated, ok := tt.translations[value]
+					if !ok {
+						t.Errorf("%s: unexpected translatable value %q", modeName(mode), value)
+						continue
+					}
+					if seg.IsXML {
+						translated = html.EscapeString(translated)
+					}
+					segments[i].Content = translated
+				}
+				if got := Reconstruct(segments); got != tt.want {
+					t.Errorf("%s: translated = %q, want %q", modeName(mode), got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,687 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// InlineTagはプレースホルダタグに置き換えられたインライン要素の元のMarkdownを保持します。
+// タグのidはSegment.Tags内のインデックスに対応します。
+type InlineTag struct {
+	// Openは開始タグまたは自己終了タグに対応するMarkdownです。
+	Open string
+	// Closeは終了タグに対応するMarkdownです。自己終了タグの場合は空です。
+	Close string
+	// Protectedは保護するパターンに一致した文字列の自己終了タグであることを示します。
+	Protected bool
+}
+
+// tagPatternは翻訳結果に含まれるプレースホルダタグにマッチします。
+// 翻訳サービスが属性の引用符や空白を変更する場合があるため、緩やかにマッチさせます。
+var tagPattern = regexp.MustCompile(`<x\s+id\s*=\s*["'](\d+)["']\s*(/?)>|</x\s*>`)
+
+// isInlineContainerはノードがインライン要素を直接の子に持つブロック(段落、見出し、リスト項目、テーブルセルなど)かを判定します。
+func isInlineContainer(n ast.Node) bool {
+	if n.Type() != ast.TypeBlock {
+		return false
+	}
+	child := n.FirstChild()
+	return child != nil && child.Type() == ast.TypeInline && n.Lines().Len() > 0
+}
+
+// blockEncoderはブロック内のインライン要素を、ソースの位置を追跡しながらプレースホルダタグ付きのテキストに変換します。
+type blockEncoder struct {
+	source    []byte
+	block     ast.Node
+	policy    Policy
+	protected protectedSpans
+	attrs     protectedSpans
+	pos       int
+	buf       strings.Builder
+	tags      []InlineTag
+	hasText   bool
+	// depthは変換中の強調やリンクなどの入れ子の深さです。
+	depth int
+	// segmentsは変換を終えたセグメント、segStartは変換中のセグメントの開始位置です。
+	segments []placedSegment
+	segStart int
+}
+
+// placedSegmentはソース上の範囲[start, stop)を持つセグメントです。
+type placedSegment struct {
+	Segment
+	start, stop int
+}
+
+// encodeBlockはブロックノードを1つの翻訳単位となるSegmentに変換します。
+// MDXのJSXの属性の値(attrs)を含む場合は、属性の値とその前後を別のセグメントに分割します。
+// ソース上の位置を正確に再現できない構造が含まれる場合はokにfalseを返します。
+func encodeBlock(source []byte, block ast.Node, policy Policy, protected, attrs protectedSpans) (segments []placedSegment, ok bool) {
+	start := block.Lines().At(0).Start
+	e := &blockEncoder{source: source, block: block, policy: policy, protected: protected, attrs: attrs, pos: start, segStart: start}
+	for c := block.FirstChild(); c != nil; c = c.NextSibling() {
+		if !e.encodeNode(c) {
+			return nil, false
+		}
+	}
+	e.flush(e.pos)
+	return e.segments, true
+}
+
+// flushは変換中のセグメントをstopまでのセグメントとして確定します。
+func (e *blockEncoder) flush(stop int) {
+	if stop > e.segStart {
+		e.segments = append(e.segments, placedSegment{
+			Segment: Segment{
+				Content:        e.buf.String(),
+				IsTranslatable: e.hasText,
+				IsXML:          true,
+				Tags:           e.tags,
+			},
+			start: e.segStart,
+			stop:  stop,
+		})
+	}
+	e.buf.Reset()
+	e.tags = nil
+	e.hasText = false
+	e.segStart = stop
+}
+
+// encodeNodeはインラインノードを1つ変換します。
+func (e *blockEncoder) encodeNode(n ast.Node) bool {
+	if e.policy.Skips(n.Kind()) && n.HasChildren() {
+		return e.encodeSkipped(n)
+	}
+	if n.Kind() != ast.KindText && e.protected.contains(e.pos) {
+		return e.encodeProtected(n)
+	}
+
+	switch node := n.(type) {
+	case *ast.Text:
+		return e.encodeText(node)
+	case *ast.Emphasis:
+		return e.encodeContainer(n, e.delimiterLen(node.Level, '*', '_'), func() int {
+			return e.delimiterLen(node.Level, '*', '_')
+		})
+	case *east.Strikethrough:
+		open := e.runLen('~')
+		return e.encodeContainer(n, open, func() int {
+			return e.delimiterLen(open, '~')
+		})
+	case *ast.Link:
+		return e.encodeContainer(n, e.prefixLen("["), e.linkTailLen)
+	case *ast.Image:
+		return e.encodeContainer(n, e.prefixLen("!["), e.linkTailLen)
+	case *ast.CodeSpan:
+		return e.encodeOpaque(e.codeSpanLen())
+	case *ast.RawHTML:
+		if node.Segments.Len() == 0 || node.Segments.At(0).Start != e.pos {
+			return false
+		}
+		return e.encodeOpaque(node.Segments.At(node.Segments.Len()-1).Stop - e.pos)
+	case *ast.AutoLink:
+		return e.encodeOpaque(e.autoLinkLen(node.Label(e.source)))
+	case *Math:
+		if node.Segment.Start != e.pos {
+			return false
+		}
+		return e.encodeOpaque(node.Segment.Len())
+	case *east.TaskCheckBox:
+		return e.encodeOpaque(e.taskCheckBoxLen())
+	}
+	return false
+}
+
+// encodeSkippedは除外対象のリンクや強調などを、子孫を含めて1つの自己終了タグに変換します。
+// 子孫を一度変換してソース上の終了位置を求めた後、その範囲全体を翻訳しない要素として扱います。
+func (e *blockEncoder) encodeSkipped(n ast.Node) bool {
+	start := e.pos
+	bufLen, tagsLen, hasText := e.buf.Len(), len(e.tags), e.hasText
+
+	policy, attrs := e.policy, e.attrs
+	e.policy, e.attrs = Policy{}, nil
+	ok := e.encodeNode(n)
+	e.policy, e.attrs = policy, attrs
+	if !ok {
+		return false
+	}
+
+	content := e.buf.String()[:bufLen]
+	e.buf.Reset()
+	e.buf.WriteString(content)
+	e.tags = e.tags[:tagsLen]
+	e.hasText = hasText
+
+	stop := e.pos
+	e.pos = start
+	return e.encodeOpaque(stop - start)
+}
+
+// encodeProtectedは保護された範囲内にあるリンクや強調などを、範囲に収まる場合に1つの自己終了タグに変換します。
+// "{% include x param="*a*" %}"のように、テンプレート構文の引数がMarkdownとして解釈された場合に使用します。
+func (e *blockEncoder) encodeProtected(n ast.Node) bool {
+	start := e.pos
+	bufLen, tagsLen, hasText := e.buf.Len(), len(e.tags), e.hasText
+
+	protected, attrs := e.protected, e.attrs
+	e.protected, e.attrs = nil, nil
+	ok := e.encodeNode(n)
+	e.protected, e.attrs = protected, attrs
+	if !ok || !e.protected.within(start, e.pos) {
+		return ok
+	}
+
+	content := e.buf.String()[:bufLen]
+	e.buf.Reset()
+	e.buf.WriteString(content)
+	e.tags = e.tags[:tagsLen]
+	e.hasText = hasText
+	e.writeProtected(string(e.source[start:e.pos]))
+	return true
+}
+
+// encodeTextはテキストノードをエスケープして書き込み、後続の改行を処理します。
+func (e *blockEncoder) encodeText(node *ast.Text) bool {
+	start := node.Segment.Start
+	if start < e.pos && e.pos < node.Segment.Stop && e.attrs.overlaps(start, e.pos) {
+		// 前のテキストノードから続くJSXの属性の値は変換済みのため、残りの部分のみを変換する
+		start = e.pos
+	}
+	if start != e.pos {
+		return false
+	}
+	value := node.Segment.Value(e.source)
+	if node.IsRaw() {
+		if !e.encodeOpaque(len(value)) {
+			return false
+		}
+	} else {
+		ok := true
+		e.attrs.cut(start, node.Segment.Stop, func(start, stop int, isAttr bool) {
+			if isAttr {
+				ok = ok && e.encodeAttr(start)
+				return
+			}
+			// 保護するパターンに一致した部分は翻訳しない自己終了タグにする
+			e.protected.cut(start, stop, func(start, stop int, protected bool) {
+				if protected {
+					e.writeProtected(string(e.source[start:stop]))
+				} else {
+					e.writeText(e.source[start:stop])
+				}
+			})
+			e.pos = stop
+		})
+		if !ok {
+			return false
+		}
+	}
+	if e.pos > node.Segment.Stop {
+		// 属性の値が改行を含む場合は位置を追跡できない
+		return !node.SoftLineBreak() && !node.HardLineBreak()
+	}
+	if !node.SoftLineBreak() && !node.HardLineBreak() {
+		return true
+	}
+
+	lineEnd := e.pos
+	if !e.skipLineBreak() {
+		return false
+	}
+	if e.protected.contains(lineEnd) || e.protected.contains(e.pos) || e.protected.contains(e.lineStart(lineEnd)) {
+		// 複数行にわたるショートコードや、保護された文字列で始まる行(":::note"など)の前後の改行は空白に置き換えない
+		e.writeProtected(string(e.source[lineEnd:e.pos]))
+	} else if node.HardLineBreak() {
+		e.writeSelfClosing(string(e.source[lineEnd:e.pos]))
+	} else {
+		// ソフト改行は文の途中で分割されないよう空白として翻訳に渡す
+		e.buf.WriteString(" ")
+	}
+	return true
+}
+
+// encodeAttrはposを含むJSXの属性の値を、前後とは別のセグメントとして確定します。
+// 属性の値は強調やリンクの内側にある場合は分割できないため、falseを返します。
+func (e *blockEncoder) encodeAttr(pos int) bool {
+	attr, _ := e.attrs.spanAt(pos)
+	if e.depth > 0 {
+		return false
+	}
+	e.flush(attr.start)
+	seg := encodeProtectedText(e.source, attr.start, attr.stop, nil)
+	seg.quote = attr.quote
+	e.segments = append(e.segments, placedSegment{Segment: seg, start: attr.start, stop: attr.stop})
+	e.segStart = attr.stop
+	e.pos = attr.stop
+	return true
+}
+
+// encodeContainerは強調やリンクなど子を持つインライン要素を対になるタグに変換します。
+func (e *blockEncoder) encodeContainer(n ast.Node, openLen int, closeLen func() int) bool {
+	if openLen <= 0 {
+		return false
+	}
+	start := e.pos
+	e.pos += openLen
+	id := len(e.tags)
+	e.tags = append(e.tags, InlineTag{Open: string(e.source[start:e.pos])})
+
+	if !n.HasChildren() {
+		// 空のリンクなどは翻訳対象がないため自己終了タグにまとめる
+		l := closeLen()
+		if l <= 0 {
+			return false
+		}
+		e.pos += l
+		e.tags[id].Open = string(e.source[start:e.pos])
+		fmt.Fprintf(&e.buf, `<x id="%d"/>`, id)
+		return true
+	}
+
+	fmt.Fprintf(&e.buf, `<x id="%d">`, id)
+	e.depth++
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if !e.encodeNode(c) {
+			return false
+		}
+	}
+	e.depth--
+	l := closeLen()
+	if l <= 0 {
+		return false
+	}
+	e.tags[id].Close = string(e.source[e.pos : e.pos+l])
+	e.pos += l
+	e.buf.WriteString("</x>")
+	return true
+}
+
+// encodeOpaqueは現在位置からlengthバイトを翻訳しない自己終了タグに変換します。
+func (e *blockEncoder) encodeOpaque(length int) bool {
+	if length <= 0 || e.pos+length > len(e.source) {
+		return false
+	}
+	e.writeSelfClosing(string(e.source[e.pos : e.pos+length]))
+	e.pos += length
+	return true
+}
+
+func (e *blockEncoder) writeSelfClosing(markup string) {
+	fmt.Fprintf(&e.buf, `<x id="%d"/>`, len(e.tags))
+	e.tags = append(e.tags, InlineTag{Open: markup})
+}
+
+// writeProtectedは保護するパターンに一致した文字列を自己終了タグとして書き込みます。
+// 直前に書き込んだタグも保護された文字列の場合は、タグを増やさずにそのタグに連結します。
+func (e *blockEncoder) writeProtected(markup string) {
+	if last := len(e.tags) - 1; last >= 0 && e.tags[last].Protected && strings.HasSuffix(e.buf.String(), fmt.Sprintf(`<x id="%d"/>`, last)) {
+		e.tags[last].Open += markup
+		return
+	}
+	fmt.Fprintf(&e.buf, `<x id="%d"/>`, len(e.tags))
+	e.tags = append(e.tags, InlineTag{Open: markup, Protected: true})
+}
+
+func (e *blockEncoder) writeText(value []byte) {
+	if len(bytes.TrimSpace(value)) > 0 {
+		e.hasText = true
+	}
+	e.buf.WriteString(html.EscapeString(string(value)))
+}
+
+// skipLineBreakは行末の空白やバックスラッシュ、改行、次の行の行頭(引用符やインデント)を読み飛ばします。
+func (e *blockEncoder) skipLineBreak() bool {
+	i := e.pos
+	for i < len(e.source) && e.source[i] != '\n' {
+		switch e.source[i] {
+		case ' ', '\t', '\\', '\r':
+			i++
+		default:
+			return false
+		}
+	}
+	if i >= len(e.source) {
+		return false
+	}
+
+	// 次の行の開始位置はブロックの行情報から求める(引用の"> "などを含まないため)
+	lines := e.block.Lines()
+	next := -1
+	for j := 0; j < lines.Len(); j++ {
+		if lines.At(j).Start > i {
+			next = lines.At(j).Start
+			break
+		}
+	}
+	if next < 0 {
+		return false
+	}
+	for next < len(e.source) && (e.source[next] == ' ' || e.source[next] == '\t') {
+		next++
+	}
+	e.pos = next
+	return true
+}
+
+// lineStartはposを含む行の、引用符やインデントを除いた開始位置を返します。
+func (e *blockEncoder) lineStart(pos int) int {
+	lines := e.block.Lines()
+	for j := lines.Len() - 1; j >= 0; j-- {
+		start := lines.At(j).Start
+		if start > pos {
+			continue
+		}
+		for start < len(e.source) && (e.source[start] == ' ' || e.source[start] == '\t') {
+			start++
+		}
+		return start
+	}
+	return pos
+}
+
+// delimiterLenは現在位置にlength個の区切り文字が並んでいる場合にlengthを返します。
+func (e *blockEncoder) delimiterLen(length int, chars ...byte) int {
+	if length <= 0 || e.pos+length > len(e.source) {
+		return 0
+	}
+	for _, c := range chars {
+		if bytes.Count(e.source[e.pos:e.pos+length], []byte{c}) == length {
+			return length
+		}
+	}
+	return 0
+}
+
+// runLenは現在位置から連続するcの個数を返します。
+func (e *blockEncoder) runLen(c byte) int {
+	n := 0
+	for e.pos+n < len(e.source) && e.source[e.pos+n] == c {
+		n++
+	}
+	return n
+}
+
+// prefixLenは現在位置がprefixで始まる場合にその長さを返します。
+func (e *blockEncoder) prefixLen(prefix string) int {
+	if bytes.HasPrefix(e.source[e.pos:], []byte(prefix)) {
+		return len(prefix)
+	}
+	return 0
+}
+
+// codeSpanLenは現在位置から始まるコードスパン全体の長さを返します。
+func (e *blockEncoder) codeSpanLen() int {
+	open := e.runLen('`')
+	if open == 0 {
+		return 0
+	}
+	for i := e.pos + open; i < len(e.source); {
+		if e.source[i] != '`' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(e.source) && e.source[j] == '`' {
+			j++
+		}
+		if j-i == open {
+			return j - e.pos
+		}
+		i = j
+	}
+	return 0
+}
+
+// autoLinkLenは現在位置から始まる自動リンク(<...>形式またはGFMのリンク化)の長さを返します。
+func (e *blockEncoder) autoLinkLen(label []byte) int {
+	rest := e.source[e.pos:]
+	if len(rest) > 0 && rest[0] == '<' {
+		if bytes.HasPrefix(rest[1:], label) && len(rest) > len(label)+1 && rest[len(label)+1] == '>' {
+			return len(label) + 2
+		}
+		return 0
+	}
+	if bytes.HasPrefix(rest, label) {
+		return len(label)
+	}
+	return 0
+}
+
+// taskCheckBoxLenは現在位置のタスクリストのチェックボックス("[ ]"や"[x]")と後続の空白の長さを返します。
+func (e *blockEncoder) taskCheckBoxLen() int {
+	rest := e.source[e.pos:]
+	if len(rest) < 3 || rest[0] != '[' || rest[2] != ']' {
+		return 0
+	}
+	n := 3
+	for n < len(rest) && (rest[n] == ' ' || rest[n] == '\t') {
+		n++
+	}
+	return n
+}
+
+// linkTailLenはリンクテキストの後ろに続く"](destination "title")"や"][label]"、"]"の長さを返します。
+func (e *blockEncoder) linkTailLen() int {
+	s := e.source
+	i := e.pos
+	if i >= len(s) || s[i] != ']' {
+		return 0
+	}
+	i++
+	if i >= len(s) {
+		return 1
+	}
+	switch s[i] {
+	case '[':
+		end := bytes.IndexByte(s[i:], ']')
+		if end < 0 {
+			return 0
+		}
+		return i + end + 1 - e.pos
+	case '(':
+		end := scanInlineLinkTail(s, i+1)
+		if end < 0 {
+			return 0
+		}
+		return end - e.pos
+	}
+	return 1
+}
+
+// scanInlineLinkTailはインラインリンクの"("の直後から対応する")"の直後の位置を返します。
+// goldmarkが構文を検証済みのため、宛先とタイトルの区切りのみを追跡します。
+func scanInlineLinkTail(s []byte, i int) int {
+	skipSpace := func() {
+		for i < len(s) && unicode.IsSpace(rune(s[i])) {
+			i++
+		}
+	}
+
+	skipSpace()
+	// 宛先
+	if i < len(s) && s[i] == '<' {
+		for i < len(s) && s[i] != '>' {
+			if s[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		i++
+	} else {
+		depth := 0
+		for i < len(s) && !unicode.IsSpace(rune(s[i])) {
+			if s[i] == '\\' {
+				i += 2
+				continue
+			}
+			if s[i] == '(' {
+				depth++
+			} else if s[i] == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+			i++
+		}
+	}
+	skipSpace()
+	// タイトル
+	if i < len(s) && (s[i] == '"' || s[i] == '\'' || s[i] == '(') {
+		closer := s[i]
+		if closer == '(' {
+			closer = ')'
+		}
+		i++
+		for i < len(s) && s[i] != closer {
+			if s[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		i++
+		skipSpace()
+	}
+	if i >= len(s) || s[i] != ')' {
+		return -1
+	}
+	return i + 1
+}
+
+// decodedTokenはデコード中のテキストまたはタグを表します。
+type decodedToken struct {
+	kind tokenKind
+	text string
+}
+
+type tokenKind int
+
+const (
+	tokenText tokenKind = iota
+	tokenOpen
+	tokenClose
+	tokenSelfClosing
+)
+
+// decodeBlockはプレースホルダタグを含むテキストを元のインライン要素を含むMarkdownに戻します。
+func decodeBlock(content string, tags []InlineTag) string {
+	var tokens []decodedToken
+	var stack []int
+	last := 0
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(content, -1) {
+		if m[0] > last {
+			tokens = append(tokens, decodedToken{kind: tokenText, text: html.UnescapeString(content[last:m[0]])})
+		}
+		last = m[1]
+
+		if m[2] < 0 {
+			// 対応する開始タグのない終了タグは無視する
+			if len(stack) == 0 {
+				continue
+			}
+			id := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			tokens = append(tokens, decodedToken{kind: tokenClose, text: tags[id].Close})
+			continue
+		}
+
+		id, err := strconv.Atoi(content[m[2]:m[3]])
+		if err != nil || id >= len(tags) {
+			continue
+		}
+		if tags[id].Close == "" {
+			tokens = append(tokens, decodedToken{kind: tokenSelfClosing, text: tags[id].Open})
+			continue
+		}
+		if m[5] > m[4] {
+			// 内容が失われた対になるタグは空の強調などになるため出力しない(MissingTagsで検出される)
+			continue
+		}
+		stack = append(stack, id)
+		tokens = append(tokens, decodedToken{kind: tokenOpen, text: tags[id].Open})
+	}
+	if last < len(content) {
+		tokens = append(tokens, decodedToken{kind: tokenText, text: html.UnescapeString(content[last:])})
+	}
+	// 閉じられていないタグは末尾で閉じる
+	for i := len(stack) - 1; i >= 0; i-- {
+		tokens = append(tokens, decodedToken{kind: tokenClose, text: tags[stack[i]].Close})
+	}
+
+	moveSpacesOutside(tokens)
+
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString(t.text)
+	}
+	return b.String()
+}
+
+// MissingTagsは翻訳結果translatedで失われたか形が変わった、プレースホルダタグの元のMarkdownを返します。
+// translatedはセグメントのContentを翻訳したXMLです。対になるタグは開始タグと終了タグの組として、
+// それ以外のタグ(コード、リンク、保護された文字列など)は1つ以上含まれている必要があります。
+// 失われたタグはデコード時に元に戻せないため、呼び出し側は翻訳結果を不正なものとして扱います。
+func (s Segment) MissingTags(translated string) []string {
+	found := make(map[int]bool)
+	var stack []int
+	for _, m := range tagPattern.FindAllStringSubmatch(translated, -1) {
+		if m[1] == "" {
+			// 終了タグは直前の開始タグと対応させる
+			if len(stack) > 0 {
+				found[stack[len(stack)-1]] = true
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		id, err := strconv.Atoi(m[1])
+		if err != nil || id >= len(s.Tags) {
+			continue
+		}
+		switch {
+		case s.Tags[id].Close == "":
+			found[id] = true
+		case m[2] == "":
+			stack = append(stack, id)
+		}
+	}
+
+	var missing []string
+	for id, tag := range s.Tags {
+		if !found[id] {
+			missing = append(missing, tag.Open+tag.Close)
+		}
+	}
+	return missing
+}
+
+// moveSpacesOutsideは開始タグ直後と終了タグ直前の空白をタグの外側へ移動します。
+// "** 強調**"のように区切り文字の内側に空白があると強調として解釈されないためです。
+func moveSpacesOutside(tokens []decodedToken) {
+	for i := range tokens {
+		switch tokens[i].kind {
+		case tokenOpen:
+			if i+1 < len(tokens) && tokens[i+1].kind == tokenText {
+				trimmed := strings.TrimLeftFunc(tokens[i+1].text, unicode.IsSpace)
+				space := tokens[i+1].text[:len(tokens[i+1].text)-len(trimmed)]
+				tokens[i].text = space + tokens[i].text
+				tokens[i+1].text = trimmed
+			}
+		case tokenClose:
+			if i > 0 && tokens[i-1].kind == tokenText {
+				trimmed := strings.TrimRightFunc(tokens[i-1].text, unicode.IsSpace)
+				space := tokens[i-1].text[len(trimmed):]
+				tokens[i].text += space
+				tokens[i-1].text = trimmed
+			}
+		}
+	}
+}
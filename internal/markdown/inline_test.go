@@ -0,0 +1,207 @@
+package markdown
+
+import (
+	"reflect"
+	"testing"
+)
+
+// translatableSegmentsはsourceをブロック単位モードで解析し、翻訳対象のセグメントを返します。
+func translatableSegments(t *testing.T, source string) []Segment {
+	t.Helper()
+	segments, err := NewParser(WithMode(ModeBlock)).Parse([]byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var translatable []Segment
+	for _, seg := range segments {
+		if seg.IsTranslatable {
+			translatable = append(translatable, seg)
+		}
+	}
+	return translatable
+}
+
+func TestEncodeBlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		content  string
+		tags     []InlineTag
+		markdown string // デコードしたMarkdown。ソフト改行は空白になる
+	}{
+		{
+			name:     "plain text is escaped",
+			source:   "a < b & c\n",
+			content:  "a &lt; b &amp; c",
+			markdown: "a < b & c",
+		},
+		{
+			name:     "emphasis",
+			source:   "Some **bold** text.\n",
+			content:  `Some <x id="0">bold</x> text.`,
+			tags:     []InlineTag{{Open: "**", Close: "**"}},
+			markdown: "Some **bold** text.",
+		},
+		{
+			name:     "nested emphasis",
+			source:   "*a **b** c*\n",
+			content:  `<x id="0">a <x id="1">b</x> c</x>`,
+			tags:     []InlineTag{{Open: "*", Close: "*"}, {Open: "**", Close: "**"}},
+			markdown: "*a **b** c*",
+		},
+		{
+			name:     "link with title",
+			source:   "See [the docs](https://example.com \"Docs\") now.\n",
+			content:  `See <x id="0">the docs</x> now.`,
+			tags:     []InlineTag{{Open: "[", Close: `](https://example.com "Docs")`}},
+			markdown: "See [the docs](https://example.com \"Docs\") now.",
+		},
+		{
+			name:     "code span",
+			source:   "Run `make` first.\n",
+			content:  `Run <x id="0"/> first.`,
+			tags:     []InlineTag{{Open: "`make`"}},
+			markdown: "Run `make` first.",
+		},
+		{
+			name:     "soft line break in a block quote",
+			source:   "> quoted *text*\n> continues\n",
+			content:  `quoted <x id="0">text</x> continues`,
+			tags:     []InlineTag{{Open: "*", Close: "*"}},
+			markdown: "quoted *text* continues",
+		},
+		{
+			name:     "heading",
+			source:   "## Install *now*\n",
+			content:  `Install <x id="0">now</x>`,
+			tags:     []InlineTag{{Open: "*", Close: "*"}},
+			markdown: "Install *now*",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := translatableSegments(t, tt.source)
+			if len(segments) != 1 {
+				t.Fatalf("got %d translatable segments, want 1: %+v", len(segments), segments)
+			}
+			seg := segments[0]
+			if !seg.IsXML {
+				t.Error("segment is not XML")
+			}
+			if seg.Content != tt.content {
+				t.Errorf("Content = %q, want %q", seg.Content, tt.content)
+			}
+			if !reflect.DeepEqual(seg.Tags, tt.tags) {
+				t.Errorf("Tags = %+v, want %+v", seg.Tags, tt.tags)
+			}
+			if got := seg.Markdown(); got != tt.markdown {
+				t.Errorf("Markdown() = %q, want %q", got, tt.markdown)
+			}
+		})
+	}
+}
+
+func TestDecodeBlock(t *testing.T) {
+	emphasis := []InlineTag{{Open: "**", Close: "**"}, {Open: "`code`"}}
+	tests := []struct {
+		name    string
+		content string
+		tags    []InlineTag
+		want    string
+	}{
+		{
+			name:    "round trip",
+			content: `Use <x id="0">bold</x> and <x id="1"/> &amp; more.`,
+			tags:    emphasis,
+			want:    "Use **bold** and `code` & more.",
+		},
+		{
+			name:    "reordered tags",
+			content: `<x id="1"/> と <x id="0">太字</x>`,
+			tags:    emphasis,
+			want:    "`code` と **太字**",
+		},
+		{
+			name:    "quotes and spaces changed by the translator",
+			content: `<x id='0' >bold</x ><x  id="1" />`,
+			tags:    emphasis,
+			want:    "**bold**`code`",
+		},
+		{
+			name:    "spaces inside tags are moved outside",
+			content: `a<x id="0"> bold </x>b`,
+			tags:    emphasis,
+			want:    "a **bold** b",
+		},
+		{
+			name:    "unknown id and unmatched closing tag are dropped",
+			content: `a <x id="9"/>b</x> c`,
+			tags:    emphasis,
+			want:    "a b c",
+		},
+		{
+			name:    "unclosed tag is closed at the end",
+			content: `a <x id="0">bold`,
+			tags:    emphasis,
+			want:    "a **bold**",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeBlock(tt.content, tt.tags); got != tt.want {
+				t.Errorf("decodeBlock(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingTags(t *testing.T) {
+	seg := Segment{Tags: []InlineTag{{Open: "**", Close: "**"}, {Open: "`code`"}, {Open: "[", Close: "](a.md)"}, {Open: "{{< ref >}}", Protected: true}}}
+	tests := []struct {
+		name       string
+		translated string
+		want       []string
+	}{
+		{name: "all tags", translated: `<x id="2">link</x> <x id="0">bold</x> <x id="1"/> <x id="3"/>`},
+		{name: "nested and reordered", translated: `<x id="2"><x id="0">bold</x> link</x> <x id='3' /><x id="1"></x>`},
+		{name: "self-closing form of a paired tag", translated: `<x id="0"/> <x id="1"/> <x id="2">link</x> <x id="3"/>`, want: []string{"****"}},
+		{name: "missing closing tag", translated: `<x id="0">bold <x id="1"/> <x id="2">link</x> <x id="3"/>`, want: []string{"****"}},
+		{name: "dropped opaque tags", translated: `<x id="0">bold</x> <x id="2">link</x>`, want: []string{"`code`", "{{< ref >}}"}},
+		{name: "dropped link", translated: `<x id="0">bold</x> link <x id="1"/> <x id="3"/>`, want: []string{"[](a.md)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := seg.MissingTags(tt.translated); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MissingTags(%q) = %q, want %q", tt.translated, got, tt.want)
+			}
+		})
+	}
+}
+
+// エンコードしたセグメントをそのままデコードすると、元のMarkdownに戻る
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	sources := []string{
+		"Plain & simple <text>.\n",
+		"Some **bold**, *italic*, ~~struck~~ and `code`.\n",
+		"A [link](https://example.com) and ![image](a.png \"title\").\n",
+		"Line one  \nline two\\\nline three\n",
+		"- item with **bold**\n- [ ] task\n",
+		"> quoted *text*\n",
+		"| a | **b** |\n| - | - |\n| `c` | d |\n",
+		"<https://example.com> and https://example.org\n",
+	}
+	for _, source := range sources {
+		segments, err := NewParser(WithMode(ModeBlock)).Parse([]byte(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, seg := range segments {
+			if seg.IsXML && seg.Markdown() != decodeBlock(seg.Content, seg.Tags) {
+				t.Errorf("Markdown() does not decode %q", seg.Content)
+			}
+		}
+		if got := Reconstruct(segments); got != source {
+			t.Errorf("Reconstruct = %q, want %q", got, source)
+		}
+	}
+}
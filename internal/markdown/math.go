@@ -0,0 +1,156 @@
+package markdown
+
+import (
+	"bytes"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindMathはインライン数式($...$または$$...$$)のノードの種類です。
+var KindMath = ast.NewNodeKind("Math")
+
+// Mathはインライン数式を表すノードです。
+type Math struct {
+	ast.BaseInline
+	// Segmentは区切り文字の$を含む数式全体のソース上の位置です。
+	Segment text.Segment
+}
+
+// Kindはノードの種類を返します。
+func (n *Math) Kind() ast.NodeKind {
+	return KindMath
+}
+
+// Dumpはデバッグ用にノードの内容を出力します。
+func (n *Math) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Value": string(n.Segment.Value(source))}, nil)
+}
+
+// KindMathBlockはディスプレイ数式($$で囲まれた行)のノードの種類です。
+var KindMathBlock = ast.NewNodeKind("MathBlock")
+
+// MathBlockはディスプレイ数式を表すノードです。
+type MathBlock struct {
+	ast.BaseBlock
+}
+
+// Kindはノードの種類を返します。
+func (n *MathBlock) Kind() ast.NodeKind {
+	return KindMathBlock
+}
+
+// IsRawはノードの内容がMarkdownとして解析されないことを示します。
+func (n *MathBlock) IsRaw() bool {
+	return true
+}
+
+// Dumpはデバッグ用にノードの内容を出力します。
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// mathExtensionは数式を認識するgoldmarkの拡張です。
+// goldmarkは数式に対応していないため、翻訳対象から除外する目的で最小限の構文のみを解析します。
+type mathExtension struct{}
+
+// Extendはgoldmarkに数式のパーサーを追加します。
+func (mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&mathBlockParser{}, 650)),
+		parser.WithInlineParsers(util.Prioritized(&mathInlineParser{}, 150)),
+	)
+}
+
+var mathDelimiter = []byte("$$")
+
+// mathBlockParserは"$$"で始まる行から"$$"で終わる行までをMathBlockとして解析します。
+type mathBlockParser struct{}
+
+func (b *mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (b *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], mathDelimiter) {
+		return nil, parser.NoChildren
+	}
+	node := &MathBlock{}
+	node.Lines().Append(segment)
+	reader.AdvanceToEOL()
+
+	// "$$ x $$"のように1行で閉じている場合
+	rest := bytes.TrimSpace(line[pos+len(mathDelimiter):])
+	if len(rest) >= len(mathDelimiter) && bytes.HasSuffix(rest, mathDelimiter) {
+		return node, parser.Close
+	}
+	return node, parser.NoChildren
+}
+
+func (b *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+	node.Lines().Append(segment)
+	reader.AdvanceToEOL()
+	if bytes.HasSuffix(bytes.TrimSpace(line), mathDelimiter) {
+		return parser.Close
+	}
+	return parser.Continue | parser.NoChildren
+}
+
+func (b *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (b *mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (b *mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// mathInlineParserは同じ行内の"$...$"または"$$...$$"をMathとして解析します。
+// 通貨表記("$5 and $10")と区別するため、開始の$の直後と終了の$の直前に空白がないこと、
+// 開始の$の直前と終了の$の直後が数字でないことを条件とします。
+type mathInlineParser struct{}
+
+func (s *mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (s *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	if unicode.IsDigit(block.PrecendingCharacter()) {
+		return nil
+	}
+	line, segment := block.PeekLine()
+	opener := 1
+	if bytes.HasPrefix(line, mathDelimiter) {
+		opener = 2
+	}
+	if len(line) <= opener || util.IsSpace(line[opener]) {
+		return nil
+	}
+	delimiter := line[:opener]
+	for i := opener + 1; i+opener <= len(line); i++ {
+		if line[i-1] == '\\' || !bytes.HasPrefix(line[i:], delimiter) {
+			continue
+		}
+		if util.IsSpace(line[i-1]) {
+			return nil
+		}
+		end := i + opener
+		if end < len(line) && unicode.IsDigit(rune(line[end])) {
+			return nil
+		}
+		block.Advance(end)
+		return &Math{Segment: text.NewSegment(segment.Start, segment.Start+end)}
+	}
+	return nil
+}
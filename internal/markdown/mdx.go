@@ -0,0 +1,367 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/util"
+)
+
+// DefaultMDXPropsはMDXのJSXの属性のうち、既定で文字列の値を翻訳する属性名です。
+var DefaultMDXProps = []string{"title", "label", "alt", "description"}
+
+// esmPatternはMDXのESM(import文とexport文)の開始行にマッチします。
+var esmPattern = regexp.MustCompile(`^(?:import|export)\b`)
+
+// mdxParserOptionはMDXとして解析するgoldmarkのパーサーを設定します。
+// JSXがHTMLとして解釈されないよう、HTMLブロックとインラインHTMLのパーサーを使用しません。
+// また、MDXと同様にインデントによるコードブロックを使用しません(JSXの子要素をインデントできるようにするため)。
+func mdxParserOption() goldmark.Option {
+	return goldmark.WithParser(parser.NewParser(
+		parser.WithBlockParsers(
+			util.Prioritized(parser.NewSetextHeadingParser(), 100),
+			util.Prioritized(parser.NewThematicBreakParser(), 200),
+			util.Prioritized(parser.NewListParser(), 300),
+			util.Prioritized(parser.NewListItemParser(), 400),
+			util.Prioritized(parser.NewATXHeadingParser(), 600),
+			util.Prioritized(parser.NewFencedCodeBlockParser(), 700),
+			util.Prioritized(parser.NewBlockquoteParser(), 800),
+			util.Prioritized(parser.NewParagraphParser(), 1000),
+		),
+		parser.WithInlineParsers(
+			util.Prioritized(parser.NewCodeSpanParser(), 100),
+			util.Prioritized(parser.NewLinkParser(), 200),
+			util.Prioritized(parser.NewAutoLinkParser(), 300),
+			util.Prioritized(parser.NewEmphasisParser(), 500),
+		),
+		parser.WithParagraphTransformers(parser.DefaultParagraphTransformers()...),
+	))
+}
+
+// mdxScannerはMDXのソースから、翻訳しないESM、JSXのタグ、式({...})の範囲と、
+// 翻訳するJSXの属性の値の範囲を求めます。
+// コードブロックとインラインコードの中は対象にしません。
+type mdxScanner struct {
+	source    []byte
+	props     []string
+	protected protectedSpans
+	attrs     protectedSpans
+}
+
+// scanMDXはsourceのoffset以降をMDXとして走査し、保護する範囲と翻訳する属性の値の範囲を返します。
+func scanMDX(source []byte, offset int, props []string) (protected, attrs protectedSpans) {
+	s := &mdxScanner{source: source, props: props}
+	s.scan(offset)
+	return s.protected, s.attrs
+}
+
+func (s *mdxScanner) scan(pos int) {
+	src := s.source
+	lineStart, blankBefore := true, true
+	for pos < len(src) {
+		if lineStart {
+			line, next := lineAt(src, pos)
+			trimmed := bytes.TrimLeft(line, " \t")
+			switch {
+			case len(bytes.TrimSpace(line)) == 0:
+				blankBefore = true
+				pos = next
+				continue
+			case blankBefore && esmPattern.Match(line):
+				// ESMは空行までを1つのブロックとして扱う
+				end := pos
+				for end < len(src) {
+					l, n := lineAt(src, end)
+					if len(bytes.TrimSpace(l)) == 0 {
+						break
+					}
+					end = n
+				}
+				s.protect(pos, trimLineEnd(src, pos, end))
+				pos = end
+				continue
+			case bytes.HasPrefix(trimmed, []byte("```")) || bytes.HasPrefix(trimmed, []byte("~~~")):
+				pos = skipFence(src, pos, trimmed)
+				blankBefore = false
+				continue
+			}
+			lineStart, blankBefore = false, false
+		}
+
+		switch c := src[pos]; c {
+		case '\n':
+			lineStart = true
+			pos++
+		case '\\':
+			pos++
+			if pos < len(src) && src[pos] != '\n' {
+				pos++
+			}
+		case '`':
+			pos += codeSpanAt(src, pos)
+		case '{':
+			if n := expressionLen(src, pos); n > 0 {
+				s.protect(pos, pos+n)
+				pos += n
+			} else {
+				pos++
+			}
+		case '<':
+			if n := s.tag(pos); n > 0 {
+				pos += n
+			} else {
+				pos++
+			}
+		default:
+			pos++
+		}
+	}
+}
+
+// protectは[start, stop)を保護する範囲に加えます。
+func (s *mdxScanner) protect(start, stop int) {
+	if stop > start {
+		s.protected = append(s.protected, span{start: start, stop: stop})
+	}
+}
+
+// tagはposから始まるJSXのタグ(またはHTMLのコメント)を保護し、その長さを返します。
+// タグとして解釈できない場合は0を返します。
+// 翻訳する属性の値はタグの保護する範囲から除き、属性の値の範囲として記録します。
+func (s *mdxScanner) tag(pos int) int {
+	src := s.source
+	if bytes.HasPrefix(src[pos:], []byte("<!--")) {
+		end := bytes.Index(src[pos+4:], []byte("-->"))
+		if end < 0 {
+			return 0
+		}
+		s.protect(pos, pos+4+end+3)
+		return 4 + end + 3
+	}
+
+	// "a < b"のような比較と区別するため、"<"の直後に空白を認めない
+	i := pos + 1
+	if i < len(src) && src[i] == '/' {
+		i++
+	}
+	// フラグメント(<>と</>)以外は名前が必要
+	if i < len(src) && src[i] != '>' {
+		n := jsxNameLen(src, i)
+		if n == 0 {
+			return 0
+		}
+		i += n
+	}
+
+	var values []span
+	for {
+		i = skipSpaces(src, i)
+		if i >= len(src) {
+			return 0
+		}
+		switch {
+		case src[i] == '>':
+			i++
+		case src[i] == '/':
+			j := skipSpaces(src, i+1)
+			if j >= len(src) || src[j] != '>' {
+				return 0
+			}
+			i = j + 1
+		case src[i] == '{':
+			// {...props}のようなスプレッド属性
+			n := expressionLen(src, i)
+			if n == 0 {
+				return 0
+			}
+			i += n
+			continue
+		default:
+			n := jsxNameLen(src, i)
+			if n == 0 {
+				return 0
+			}
+			name := string(src[i : i+n])
+			i = skipSpaces(src, i+n)
+			if i >= len(src) || src[i] != '=' {
+				continue
+			}
+			i = skipSpaces(src, i+1)
+			if i >= len(src) {
+				return 0
+			}
+			switch src[i] {
+			case '"', '\'':
+				end := bytes.IndexByte(src[i+1:], src[i])
+				if end < 0 {
+					return 0
+				}
+				if end > 0 && s.translatesProp(name) {
+					values = append(values, span{start: i + 1, stop: i + 1 + end, quote: src[i]})
+				}
+				i += end + 2
+			case '{':
+				n := expressionLen(src, i)
+				if n == 0 {
+					return 0
+				}
+				i += n
+			default:
+				return 0
+			}
+			continue
+		}
+		break
+	}
+
+	// 翻訳する属性の値を除いた部分を保護する
+	start := pos
+	for _, v := range values {
+		s.protect(start, v.start)
+		s.attrs = append(s.attrs, v)
+		start = v.stop
+	}
+	s.protect(start, i)
+	return i - pos
+}
+
+// translatesPropはJSXの属性の値を翻訳するかを返します。
+func (s *mdxScanner) translatesProp(name string) bool {
+	for _, p := range s.props {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// jsxNameLenはposから始まるJSXの要素名または属性名の長さを返します。
+func jsxNameLen(src []byte, pos int) int {
+	i := pos
+	for i < len(src) {
+		c := src[i]
+		if c == '_' || c == '$' || c >= 0x80 || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') ||
+			(i > pos && (('0' <= c && c <= '9') || c == '-' || c == '.' || c == ':')) {
+			i++
+			continue
+		}
+		break
+	}
+	return i - pos
+}
+
+// expressionLenはposの"{"から対応する"}"までの長さを返します。対応する"}"がない場合は0を返します。
+// 文字列とコメントの中の括弧は数えません。
+func expressionLen(src []byte, pos int) int {
+	depth := 0
+	for i := pos; i < len(src); i++ {
+		switch src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1 - pos
+			}
+		case '"', '\'', '`':
+			quote := src[i]
+			for i++; i < len(src) && src[i] != quote; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		case '/':
+			if i+1 < len(src) && src[i+1] == '/' {
+				for i < len(src) && src[i] != '\n' {
+					i++
+				}
+			} else if i+1 < len(src) && src[i+1] == '*' {
+				end := bytes.Index(src[i+2:], []byte("*/"))
+				if end < 0 {
+					return 0
+				}
+				i += 2 + end + 1
+			}
+		}
+	}
+	return 0
+}
+
+// codeSpanAtはposから始まるインラインコードの長さを返します。閉じられていない場合はバッククォートの長さを返します。
+func codeSpanAt(src []byte, pos int) int {
+	open := 0
+	for pos+open < len(src) && src[pos+open] == '`' {
+		open++
+	}
+	for i := pos + open; i < len(src); {
+		if src[i] != '`' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(src) && src[j] == '`' {
+			j++
+		}
+		if j-i == open {
+			return j - pos
+		}
+		i = j
+	}
+	return open
+}
+
+// skipFenceはposの行から始まるフェンスで囲まれたコードブロックの直後の位置を返します。
+// trimmedは行頭の空白を除いた開始行です。
+func skipFence(src []byte, pos int, trimmed []byte) int {
+	fence := trimmed[0]
+	n := 0
+	for n < len(trimmed) && trimmed[n] == fence {
+		n++
+	}
+	closing := strings.Repeat(string(fence), n)
+	_, next := lineAt(src, pos)
+	for next < len(src) {
+		line, end := lineAt(src, next)
+		if bytes.HasPrefix(bytes.TrimLeft(line, " \t"), []byte(closing)) {
+			return end
+		}
+		next = end
+	}
+	return len(src)
+}
+
+// lineAtはposから行末までの内容と、次の行の開始位置を返します。
+func lineAt(src []byte, pos int) (line []byte, next int) {
+	end := bytes.IndexByte(src[pos:], '\n')
+	if end < 0 {
+		return src[pos:], len(src)
+	}
+	return src[pos : pos+end], pos + end + 1
+}
+
+// trimLineEndはstopの直前の改行を除いた位置を返します。
+func trimLineEnd(src []byte, start, stop int) int {
+	for stop > start && (src[stop-1] == '\n' || src[stop-1] == '\r') {
+		stop--
+	}
+	return stop
+}
+
+// escapeQuoteはJSXの属性の値に含まれる引用符を文字参照に置き換えます。
+func escapeQuote(value string, quote byte) string {
+	if quote == '"' {
+		return strings.ReplaceAll(value, `"`, "&quot;")
+	}
+	return strings.ReplaceAll(value, "'", "&#39;")
+}
+
+// skipSpacesはposから空白と改行を読み飛ばした位置を返します。
+func skipSpaces(src []byte, pos int) int {
+	for pos < len(src) && (src[pos] == ' ' || src[pos] == '\t' || src[pos] == '\n' || src[pos] == '\r') {
+		pos++
+	}
+	return pos
+}
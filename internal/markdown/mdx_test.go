@@ -0,0 +1,150 @@
+package markdown
+
+import (
+	"html"
+	"reflect"
+	"testing"
+)
+
+func TestScanMDX(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		protected []string
+		attrs     []string
+	}{
+		{
+			name:      "esm block until blank line",
+			source:    "import A from 'a'\nexport const x = {\n  y: 1,\n}\n\nText\n",
+			protected: []string{"import A from 'a'\nexport const x = {\n  y: 1,\n}"},
+		},
+		{
+			name:      "translated attribute is cut out of the tag",
+			source:    "<Tabs title=\"Guide\" id=\"t\" items={['a']}>\n",
+			protected: []string{`<Tabs title="`, `" id="t" items={['a']}>`},
+			attrs:     []string{"Guide"},
+		},
+		{
+			name:      "single-quoted attribute and spread",
+			source:    "<Image alt='It is' src=\"a.png\" {...rest} />",
+			protected: []string{"<Image alt='", `' src="a.png" {...rest} />`},
+			attrs:     []string{"It is"},
+		},
+		{
+			name:      "empty and expression values are not translated",
+			source:    "<Card title=\"\" label={x} description='d'/>",
+			protected: []string{`<Card title="" label={x} description='`, "'/>"},
+			attrs:     []string{"d"},
+		},
+		{
+			name:      "expression outside code span",
+			source:    "a {1 + 2} b `<X title=\"no\" />` c",
+			protected: []string{"{1 + 2}"},
+		},
+		{
+			name:      "comparison and fragments",
+			source:    "a < b and <>frag</>",
+			protected: []string{"<>", "</>"},
+		},
+		{
+			name:      "html comment",
+			source:    "<!-- note --> x",
+			protected: []string{"<!-- note -->"},
+		},
+		{
+			name:   "fenced code block",
+			source: "```js\n<Fake title=\"no\" />\n```\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			protected, attrs := scanMDX([]byte(tt.source), 0, DefaultMDXProps)
+			texts := func(spans protectedSpans) []string {
+				var s []string
+				for _, sp := range spans {
+					s = append(s, tt.source[sp.start:sp.stop])
+				}
+				return s
+			}
+			if got := texts(protected); !reflect.DeepEqual(got, tt.protected) {
+				t.Errorf("protected = %q, want %q", got, tt.protected)
+			}
+			if got := texts(attrs); !reflect.DeepEqual(got, tt.attrs) {
+				t.Errorf("attrs = %q, want %q", got, tt.attrs)
+			}
+		})
+	}
+}
+
+// ESM、JSX、式({...})はソースのまま出力し、本文とtitle、altの値のみ翻訳する
+func TestParseMDX(t *testing.T) {
+	source := "import Tabs from '@theme/Tabs'\nexport const meta = {title: 'x'}\n\n" +
+		"# Hello {props.name}\n\n" +
+		"<Tabs title=\"Install guide\" id=\"tabs\" items={['a', 'b']}>\n  Some *text* here.\n</Tabs>\n\n" +
+		"![alt text](a.png)\n\n" +
+		"<Image alt='It is' src=\"a.png\" />\n\n" +
+		"Value is {1 + 2} now.\n\n" +
+		"```js\n<Fake title=\"no\" />\n```\n"
+	want := "import Tabs from '@theme/Tabs'\nexport const meta = {title: 'x'}\n\n" +
+		"# HELLO {props.name}\n\n" +
+		"<Tabs title=\"INSTALL GUIDE\" id=\"tabs\" items={['a', 'b']}>\n  SOME *TEXT* HERE.\n</Tabs>\n\n" +
+		"![ALT TEXT](a.png)\n\n" +
+		"<Image alt='IT IS' src=\"a.png\" />\n\n" +
+		"VALUE IS {1 + 2} NOW.\n\n" +
+		"```js\n<Fake title=\"no\" />\n```\n"
+
+	for _, mode := range []Mode{ModeText, ModeBlock} {
+		segments, err := NewParser(WithMode(mode), WithMDX(DefaultMDXProps...)).Parse([]byte(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Reconstruct(segments); got != source {
+			t.Errorf("%s: Reconstruct = %q, want %q", modeName(mode), got, source)
+		}
+		for i, seg := range segments {
+			if seg.IsTranslatable {
+				if !seg.IsXML {
+					t.Errorf("%s: segment %q is not XML", modeName(mode), seg.Content)
+				}
+				segments[i].Content = fakeTranslate(seg.Content, seg.IsXML)
+			}
+		}
+		if got := Reconstruct(segments); got != want {
+			t.Errorf("%s: translated = %q, want %q", modeName(mode), got, want)
+		}
+	}
+}
+
+// 翻訳結果に属性の値を囲む引用符が含まれる場合は、文字参照に置き換える
+func TestMDXAttributeQuote(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		translated string
+		want       string
+	}{
+		{name: "double quote", source: `<Card title="Hello" />`, translated: `Say "hi" it's`, want: `<Card title="Say &quot;hi&quot; it's" />`},
+		{name: "single quote", source: `<Card title='Hello' />`, translated: `Say "hi" it's`, want: `<Card title='Say "hi" it&#39;s' />`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := NewParser(WithMDX(DefaultMDXProps...)).Parse([]byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			translated := 0
+			for i, seg := range segments {
+				if seg.IsTranslatable {
+					segments[i].Content = html.EscapeString(tt.translated)
+					translated++
+				}
+			}
+			if translated != 1 {
+				t.Fatalf("got %d translatable segments, want 1", translated)
+			}
+			if got := Reconstruct(segments); got != tt.want {
+				t.Errorf("translated = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
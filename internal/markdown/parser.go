@@ -1,6 +1,7 @@
 package markdown
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/yuin/goldmark"
@@ -10,44 +11,240 @@ import (
 	"github.com/yuin/goldmark/text"
 )
 
+// Modeは翻訳単位の分割方法を表します。
+type Mode int
+
+const (
+	// ModeTextはast.Textノードごとにセグメントを作成します。
+	ModeText Mode = iota
+	// ModeBlockは段落、見出し、リスト項目、テーブルセルごとにセグメントを作成します。
+	// 強調、リンク、インラインコードなどはプレースホルダタグとしてセグメント内に埋め込まれます。
+	ModeBlock
+)
+
+// ParseModeは設定ファイルの値("text"または"block")をModeに変換します。
+// 空文字列の場合はModeTextを返します。
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(s) {
+	case "", "text":
+		return ModeText, nil
+	case "block":
+		return ModeBlock, nil
+	}
+	return ModeText, fmt.Errorf("unknown translation unit %q (expected \"text\" or \"block\")", s)
+}
+
 // SegmentはMarkdownドキュメントの一部を表します。
 // 翻訳対象かどうかのフラグを持ちます。
 type Segment struct {
 	Content        string
 	IsTranslatable bool
+	// IsXMLはContentがXMLエスケープされ、プレースホルダタグを含みうることを示します。
+	// ブロック単位モード、保護するパターンがある場合、MDXの場合に生成された翻訳対象セグメントで設定されます。
+	IsXML bool
+	// TagsはContent内のプレースホルダタグに対応する元のMarkdownです。
+	Tags []InlineTag
+	// yamlはFrontmatterの値のセグメントの書式です。出力時に書式に合わせてクォートします。
+	yaml yamlStyle
+	// quoteはMDXのJSXの属性の値のセグメントを囲む引用符です。出力時に翻訳結果の引用符を文字参照に置き換えます。
+	quote byte
+}
+
+// MarkdownはセグメントをMarkdownとして出力する際の文字列を返します。
+// XMLとして扱われるセグメントはプレースホルダタグを展開し、エスケープを解除します。
+func (s Segment) Markdown() string {
+	content := s.Content
+	if s.IsXML {
+		content = decodeBlock(s.Content, s.Tags)
+	}
+	if s.yaml != yamlNone {
+		content = s.yaml.format(content)
+	}
+	if s.quote != 0 {
+		content = escapeQuote(content, s.quote)
+	}
+	return content
 }
 
 // ParserはMarkdownの解析ロジックを管理します。
 type Parser struct {
-	gm goldmark.Markdown
+	gm     goldmark.Markdown
+	mode   Mode
+	policy Policy
+	// frontmatterKeysはFrontmatterのうち、値を翻訳するキーです。
+	frontmatterKeys []string
+	protection      Protection
+	// mdxはMDXとして解析するかを表します。mdxPropsは文字列の値を翻訳するJSXの属性名です。
+	mdx      bool
+	mdxProps []string
+}
+
+// OptionはParserの設定を変更する関数です。
+type Option func(*Parser)
+
+// WithModeは翻訳単位の分割方法を指定します。
+func WithMode(mode Mode) Option {
+	return func(p *Parser) {
+		p.mode = mode
+	}
+}
+
+// WithPolicyは翻訳対象から除外するノードの種類を指定します。
+func WithPolicy(policy Policy) Option {
+	return func(p *Parser) {
+		p.policy = policy
+	}
+}
+
+// WithFrontmatterKeysはYAMLのFrontmatterのうち、値を翻訳するキーを指定します。
+// 指定しない場合、Frontmatterは翻訳しません。
+func WithFrontmatterKeys(keys ...string) Option {
+	return func(p *Parser) {
+		p.frontmatterKeys = keys
+	}
+}
+
+// WithProtectionはプレースホルダタグに置き換えて翻訳から保護するインラインのパターンを指定します。
+func WithProtection(protection Protection) Option {
+	return func(p *Parser) {
+		p.protection = protection
+	}
+}
+
+// WithMDXはMDXとして解析します。ESM、JSXのタグ、式({...})は翻訳せず、
+// JSXの子要素のMarkdownと、propsで指定した属性の文字列の値を翻訳します。
+func WithMDX(props ...string) Option {
+	return func(p *Parser) {
+		p.mdx = true
+		p.mdxProps = props
+	}
 }
 
 // NewParserは新しいParserインスタンスを作成します。
-func NewParser() *Parser {
-	gm := goldmark.New(
+func NewParser(opts ...Option) *Parser {
+	p := &Parser{policy: DefaultPolicy()}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	gmOpts := []goldmark.Option{
 		goldmark.WithExtensions(
 			extension.GFM,
+			mathExtension{},
 		),
 		goldmark.WithParserOptions(
 			parser.WithAttribute(),
 		),
-	)
-	return &Parser{gm: gm}
+	}
+	if p.mdx {
+		// 拡張機能のパーサーは、置き換えたパーサーに追加される
+		gmOpts = append([]goldmark.Option{mdxParserOption()}, gmOpts...)
+	}
+	p.gm = goldmark.New(gmOpts...)
+	return p
+}
+
+// Modeはこのパーサーの翻訳単位の分割方法を返します。
+func (p *Parser) Mode() Mode {
+	return p.mode
+}
+
+// Policyはこのパーサーが翻訳対象から除外するノードの種類を返します。
+func (p *Parser) Policy() Policy {
+	return p.policy
+}
+
+// FrontmatterKeysはこのパーサーがFrontmatterの値を翻訳するキーを返します。
+func (p *Parser) FrontmatterKeys() []string {
+	return p.frontmatterKeys
+}
+
+// Protectionはこのパーサーが翻訳から保護するインラインのパターンを返します。
+func (p *Parser) Protection() Protection {
+	return p.protection
+}
+
+// MDXはこのパーサーがMDXとして解析するかと、文字列の値を翻訳するJSXの属性名を返します。
+func (p *Parser) MDX() (enabled bool, props []string) {
+	return p.mdx, p.mdxProps
+}
+
+// emitsXMLはこのパーサーが翻訳対象のセグメントをXMLとして作成するかを返します。
+// ブロック単位モード、保護するパターンがある場合、MDXの場合は、プレースホルダタグを含むため
+// 翻訳リクエストがXMLとして送信されます。
+func (p *Parser) emitsXML() bool {
+	return p.mode == ModeBlock || p.protection.Enabled() || p.mdx
+}
+
+// translatesFrontmatterKeyはFrontmatterのキーの値を翻訳するかを返します。
+func (p *Parser) translatesFrontmatterKey(key string) bool {
+	for _, k := range p.frontmatterKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
 }
 
 // ParseはMarkdownコンテンツを読み込み、翻訳可能なセグメントとそうでないセグメントに分割します。
 func (p *Parser) Parse(source []byte) ([]Segment, error) {
+	var segments []Segment
+	var lastPos int
+
+	// Frontmatterはgoldmarkで解析する前に取り出し、本文はFrontmatterの後から解析する
+	// ノードの位置はソース全体での位置のままになる
+	kind, length := detectFrontmatter(source)
+	if kind != frontmatterNone {
+		segments = append(segments, p.frontmatterSegments(kind, string(source[:length]))...)
+		lastPos = length
+	}
 	reader := text.NewReader(source)
+	reader.Advance(length)
 	doc := p.gm.Parser().Parse(reader)
+	protected := p.protection.find(source, length)
+	var attrs protectedSpans
+	if p.mdx {
+		var jsx protectedSpans
+		jsx, attrs = scanMDX(source, length, p.mdxProps)
+		protected = append(protected, jsx...).merge()
+	}
 
-	var segments []Segment
-	var lastPos int
+	// appendSegmentは前回のセグメントの終わりからstartまでを非翻訳セグメントとして追加した上で、segを追加します。
+	appendSegment := func(start, stop int, seg Segment) {
+		if start > lastPos {
+			segments = append(segments, Segment{
+				Content:        string(source[lastPos:start]),
+				IsTranslatable: false,
+			})
+		}
+		segments = append(segments, seg)
+		lastPos = stop
+	}
 
 	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
 		if !entering {
 			return ast.WalkContinue, nil
 		}
 
+		// 除外対象のノードは子孫も含めてソースのまま出力する
+		if p.policy.Skips(n.Kind()) {
+			return ast.WalkSkipChildren, nil
+		}
+
+		// ブロック単位モードでは、インライン要素を持つブロックを1つのセグメントにまとめる
+		// テキストノード単位モードでも、保護された範囲が複数のテキストノードにまたがるブロックは、
+		// テンプレート構文を1つのプレースホルダタグにするためブロック単位で変換する
+		// 位置を正確に再現できないブロックは、テキストノード単位の処理にフォールバックする
+		if isInlineContainer(n) && (p.mode == ModeBlock || protected.crossesText(n)) {
+			segs, ok := encodeBlock(source, n, p.policy, protected, attrs)
+			if ok && len(segs) > 0 && segs[0].start >= lastPos {
+				for _, seg := range segs {
+					appendSegment(seg.start, seg.stop, seg.Segment)
+				}
+				return ast.WalkSkipChildren, nil
+			}
+		}
+
 		// テキストノード以外は処理しない
 		if n.Kind() != ast.KindText {
 			return ast.WalkContinue, nil
@@ -61,39 +258,39 @@ func (p *Parser) Parse(source []byte) ([]Segment, error) {
 		start := textNode.Segment.Start
 		stop := textNode.Segment.Stop
 
-		// 親ノードをチェックして、コードブロック内かどうかを判断
-		isTranslatable := true
-		parent := n.Parent()
-		if parent != nil {
-			pKind := parent.Kind()
-			if pKind == ast.KindFencedCodeBlock {
-				isTranslatable = false
-			}
-		}
-		if textNode.IsRaw() {
-			isTranslatable = false
-		}
+		// コードブロックなどの除外対象は上でスキップ済みのため、ここではrawテキストのみを除外する
+		isTranslatable := !textNode.IsRaw()
 
 		if start < lastPos {
-			return ast.WalkContinue, nil
-		}
-
-		// 前回のノードの終わりから今回のノードの始まりまでを非翻訳セグメントとして追加
-		if start > lastPos {
-			segments = append(segments, Segment{
-				Content:        string(source[lastPos:start]),
-				IsTranslatable: false,
-			})
+			// 前のノードから続くJSXの属性の値をセグメントにした場合は、残りの部分のみを処理する
+			if stop <= lastPos || !attrs.overlaps(start, lastPos) {
+				return ast.WalkContinue, nil
+			}
+			start = lastPos
 		}
 
 		// 今回のノードをセグメントとして追加
-		content := string(source[start:stop])
-		segments = append(segments, Segment{
-			Content:        content,
+		seg := Segment{
+			Content:        string(source[start:stop]),
 			IsTranslatable: isTranslatable,
-		})
-
-		lastPos = stop
+		}
+		// 翻訳リクエストがXMLとして送信される場合は、テキストもエスケープする
+		if p.emitsXML() && isTranslatable {
+			// JSXの属性の値は、前後のテキストとは別のセグメントにする
+			// 属性の値が複数のテキストノードに分かれている場合も、値全体を1つのセグメントにする
+			attrs.cut(start, stop, func(start, stop int, isAttr bool) {
+				var quote byte
+				if isAttr {
+					attr, _ := attrs.spanAt(start)
+					start, stop, quote = attr.start, attr.stop, attr.quote
+				}
+				seg := encodeProtectedText(source, start, stop, protected)
+				seg.quote = quote
+				appendSegment(start, stop, seg)
+			})
+			return ast.WalkContinue, nil
+		}
+		appendSegment(start, stop, seg)
 		return ast.WalkContinue, nil
 	})
 
@@ -109,23 +306,6 @@ func (p *Parser) Parse(source []byte) ([]Segment, error) {
 		})
 	}
 
-	// Frontmatterの処理は変更なし
-	if len(segments) > 0 && strings.HasPrefix(segments[0].Content, "---") {
-		var frontmatter string
-		endIndex := -1
-		for i, seg := range segments {
-			frontmatter += seg.Content
-			if i > 0 && strings.Contains(seg.Content, "---") {
-				endIndex = i
-				break
-			}
-		}
-		if endIndex != -1 {
-			newSegments := []Segment{{Content: frontmatter, IsTranslatable: false}}
-			segments = append(newSegments, segments[endIndex+1:]...)
-		}
-	}
-
 	return segments, nil
 }
 
@@ -133,7 +313,7 @@ func (p *Parser) Parse(source []byte) ([]Segment, error) {
 func Reconstruct(segments []Segment) string {
 	var builder strings.Builder
 	for _, seg := range segments {
-		builder.WriteString(seg.Content)
+		builder.WriteString(seg.Markdown())
 	}
 	return builder.String()
 }
@@ -0,0 +1,160 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// goldenKindsはtestdataのゴールデンファイルで、翻訳対象にならないことを確認するノードの種類です。
+var goldenKinds = map[ast.NodeKind]bool{
+	ast.KindCodeSpan:        true,
+	ast.KindRawHTML:         true,
+	ast.KindHTMLBlock:       true,
+	ast.KindAutoLink:        true,
+	ast.KindCodeBlock:       true,
+	ast.KindFencedCodeBlock: true,
+	KindMath:                true,
+	KindMathBlock:           true,
+}
+
+// testdata/*.mdのすべてのノードが、解析と再構築を経てバイト単位で元に戻ることと、
+// 除外対象のノードが翻訳されずに残ることを確認する
+func TestParseGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no golden files in testdata")
+	}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mode := range []Mode{ModeText, ModeBlock} {
+			t.Run(filepath.Base(file)+"/"+modeName(mode), func(t *testing.T) {
+				p := NewParser(WithMode(mode))
+				skipped := skippedTexts(t, p, source)
+				if len(skipped) == 0 {
+					t.Fatal("golden file contains no skipped nodes")
+				}
+
+				segments, err := p.Parse(source)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := Reconstruct(segments); got != string(source) {
+					t.Fatalf("Reconstruct(Parse(src)) != src\ngot:\n%s\nwant:\n%s", got, source)
+				}
+
+				for i, seg := range segments {
+					if !seg.IsTranslatable {
+						continue
+					}
+					for _, s := range skipped {
+						if strings.Contains(seg.Content, s) {
+							t.Errorf("translatable segment %q contains skipped node %q", seg.Content, s)
+						}
+					}
+					segments[i].Content = fakeTranslate(seg.Content, seg.IsXML)
+				}
+
+				// 翻訳した場合も除外対象のノードはそのまま残る
+				translated := Reconstruct(segments)
+				for _, s := range skipped {
+					if !strings.Contains(translated, s) {
+						t.Errorf("skipped node %q was changed by translation:\n%s", s, translated)
+					}
+				}
+			})
+		}
+	}
+}
+
+// skippedTextsはsourceのうち、goldenKindsのノードのソース上の文字列を返します。
+func skippedTexts(t *testing.T, p *Parser, source []byte) []string {
+	t.Helper()
+	doc := p.gm.Parser().Parse(text.NewReader(source))
+	var texts []string
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || !goldenKinds[n.Kind()] {
+			return ast.WalkContinue, nil
+		}
+		var b strings.Builder
+		switch n := n.(type) {
+		case *ast.RawHTML:
+			for i := 0; i < n.Segments.Len(); i++ {
+				seg := n.Segments.At(i)
+				b.Write(seg.Value(source))
+			}
+		case *ast.AutoLink:
+			b.Write(n.Label(source))
+		case *Math:
+			b.Write(n.Segment.Value(source))
+		default:
+			if n.Type() == ast.TypeBlock {
+				// インデントされたコードブロックの行はインデントを除いた位置を持つため、行ごとに確認する
+				lines := n.Lines()
+				for i := 0; i < lines.Len(); i++ {
+					line := lines.At(i)
+					if s := strings.TrimSpace(string(line.Value(source))); s != "" {
+						texts = append(texts, s)
+					}
+				}
+			} else {
+				for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+					if c, ok := c.(*ast.Text); ok {
+						b.Write(c.Segment.Value(source))
+					}
+				}
+			}
+		}
+		if s := strings.TrimSpace(b.String()); s != "" {
+			texts = append(texts, s)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return texts
+}
+
+// fakeTranslateは翻訳の代わりに、タグと文字参照以外の文字を大文字にします。
+func fakeTranslate(content string, isXML bool) string {
+	if !isXML {
+		return strings.ToUpper(content)
+	}
+	var b strings.Builder
+	inTag, inEntity := false, false
+	for _, r := range content {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case r == '&':
+			inEntity = true
+		case r == ';':
+			inEntity = false
+		case !inTag && !inEntity:
+			r = unicode.ToUpper(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func modeName(mode Mode) string {
+	if mode == ModeBlock {
+		return "block"
+	}
+	return "text"
+}
@@ -0,0 +1,89 @@
+package markdown
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// nodeKindsは設定ファイルで指定できるノード名と、対応するノードの種類です。
+var nodeKinds = map[string][]ast.NodeKind{
+	"code_span":         {ast.KindCodeSpan},
+	"raw_html":          {ast.KindRawHTML},
+	"html_block":        {ast.KindHTMLBlock},
+	"autolink":          {ast.KindAutoLink},
+	"code_block":        {ast.KindCodeBlock},
+	"fenced_code_block": {ast.KindFencedCodeBlock},
+	"math":              {KindMath, KindMathBlock},
+	"heading":           {ast.KindHeading},
+	"blockquote":        {ast.KindBlockquote},
+	"list":              {ast.KindList},
+	"link":              {ast.KindLink},
+	"image":             {ast.KindImage},
+	"emphasis":          {ast.KindEmphasis},
+	"strikethrough":     {east.KindStrikethrough},
+	"table":             {east.KindTable},
+}
+
+// defaultSkipNodesは常に翻訳対象から除外するノードです。
+var defaultSkipNodes = []string{
+	"code_span", "raw_html", "html_block", "autolink", "code_block", "fenced_code_block", "math",
+}
+
+// Policyは翻訳対象から除外するノードの種類を管理します。
+// 除外されたノードとその子孫は、ソースのまま出力されます。
+type Policy struct {
+	skip map[ast.NodeKind]bool
+}
+
+// DefaultPolicyはコード、HTML、自動リンク、数式を除外する既定のPolicyを返します。
+func DefaultPolicy() Policy {
+	p, _ := Policy{}.Skip(defaultSkipNodes...)
+	return p
+}
+
+// Skipは指定されたノード名を除外対象に加えた新しいPolicyを返します。
+func (p Policy) Skip(names ...string) (Policy, error) {
+	skip := make(map[ast.NodeKind]bool, len(p.skip)+len(names))
+	for kind := range p.skip {
+		skip[kind] = true
+	}
+	for _, name := range names {
+		kinds, ok := nodeKinds[strings.ToLower(name)]
+		if !ok {
+			return p, fmt.Errorf("unknown node kind %q (expected one of %s)", name, strings.Join(NodeKindNames(), ", "))
+		}
+		for _, kind := range kinds {
+			skip[kind] = true
+		}
+	}
+	return Policy{skip: skip}, nil
+}
+
+// Skipsは指定されたノードの種類が除外対象かを返します。
+func (p Policy) Skips(kind ast.NodeKind) bool {
+	return p.skip[kind]
+}
+
+// Stringは除外対象のノードの種類を名前順に並べた文字列を返します。
+func (p Policy) String() string {
+	names := make([]string, 0, len(p.skip))
+	for kind := range p.skip {
+		names = append(names, kind.String())
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// NodeKindNamesは設定ファイルで指定できるノード名の一覧を返します。
+func NodeKindNames() []string {
+	names := make([]string, 0, len(nodeKinds))
+	for name := range nodeKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
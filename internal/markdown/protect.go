@@ -0,0 +1,222 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// protectPresetsは設定ファイルで指定できるプリセット名と、保護するパターンです。
+// 静的サイトジェネレーターのテンプレート構文は通常のテキストとして解析されるため、
+// 翻訳サービスが引数を翻訳したり、括弧の前後の空白を変更したりしないように保護します。
+var protectPresets = map[string][]string{
+	// Hugoのショートコード({{< ref "x.md" >}}、{{% notice %}})
+	"hugo": {`(?s)\{\{<.*?>\}\}`, `(?s)\{\{%.*?%\}\}`},
+	// Liquid(Jekyll)とJinjaのタグ({% include %})と出力({{ page.title }})
+	"jekyll": liquidPatterns,
+	"liquid": liquidPatterns,
+	"jinja":  liquidPatterns,
+	// Docusaurusの見出しID({#id})、MDXのコメント({/* ... */})、admonitionの開始と終了(:::note)
+	"docusaurus": {`\{#[\w-]+\}`, `(?s)\{/\*.*?\*/\}`, `(?m)^:::[\w-]*`},
+}
+
+var liquidPatterns = []string{`(?s)\{%-?.*?-?%\}`, `(?s)\{\{-?.*?-?\}\}`}
+
+// Protectionは翻訳させずにプレースホルダタグに置き換えるインラインのパターンを管理します。
+// パターンに一致した部分は翻訳後に元の文字列に戻されます。
+type Protection struct {
+	patterns []*regexp.Regexp
+}
+
+// Presetは指定されたプリセットのパターンを加えた新しいProtectionを返します。
+func (p Protection) Preset(names ...string) (Protection, error) {
+	var exprs []string
+	for _, name := range names {
+		preset, ok := protectPresets[strings.ToLower(name)]
+		if !ok {
+			return p, fmt.Errorf("unknown preset %q (expected one of %s)", name, strings.Join(ProtectPresetNames(), ", "))
+		}
+		exprs = append(exprs, preset...)
+	}
+	return p.Pattern(exprs...)
+}
+
+// Patternは指定された正規表現を加えた新しいProtectionを返します。
+func (p Protection) Pattern(exprs ...string) (Protection, error) {
+	patterns := append([]*regexp.Regexp(nil), p.patterns...)
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return p, fmt.Errorf("invalid pattern %q: %w", expr, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return Protection{patterns: patterns}, nil
+}
+
+// Enabledは保護するパターンが1つ以上あるかを返します。
+func (p Protection) Enabled() bool {
+	return len(p.patterns) > 0
+}
+
+// Stringは保護するパターンを改行で区切った文字列を返します。
+func (p Protection) String() string {
+	exprs := make([]string, len(p.patterns))
+	for i, re := range p.patterns {
+		exprs[i] = re.String()
+	}
+	return strings.Join(exprs, "\n")
+}
+
+// ProtectPresetNamesは設定ファイルで指定できるプリセット名の一覧を返します。
+func ProtectPresetNames() []string {
+	names := make([]string, 0, len(protectPresets))
+	for name := range protectPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// spanはソース上の範囲[start, stop)です。
+type span struct {
+	start, stop int
+	// quoteはJSXの属性の値の範囲を囲む引用符です。
+	quote byte
+}
+
+// protectedSpansはソース上の保護された範囲を、開始位置の順に重複なく保持します。
+type protectedSpans []span
+
+// findはsourceのoffset以降で、いずれかのパターンに一致する範囲を返します。
+// 重なり合う範囲は1つにまとめます。
+func (p Protection) find(source []byte, offset int) protectedSpans {
+	var spans protectedSpans
+	for _, re := range p.patterns {
+		for _, m := range re.FindAllIndex(source[offset:], -1) {
+			if m[1] > m[0] {
+				spans = append(spans, span{start: offset + m[0], stop: offset + m[1]})
+			}
+		}
+	}
+	return spans.merge()
+}
+
+// mergeは範囲を開始位置の順に並べ、重なり合う範囲を1つにまとめたものを返します。
+func (s protectedSpans) merge() protectedSpans {
+	spans := append(protectedSpans(nil), s...)
+	sort.Slice(spans, func(a, b int) bool { return spans[a].start < spans[b].start })
+
+	var merged protectedSpans
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.start < merged[n-1].stop {
+			if s.stop > merged[n-1].stop {
+				merged[n-1].stop = s.stop
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// containsはposが保護された範囲に含まれるかを返します。
+func (s protectedSpans) contains(pos int) bool {
+	i := sort.Search(len(s), func(i int) bool { return s[i].stop > pos })
+	return i < len(s) && s[i].start <= pos
+}
+
+// overlapsは[start, stop)と重なる範囲があるかを返します。
+func (s protectedSpans) overlaps(start, stop int) bool {
+	i := sort.Search(len(s), func(i int) bool { return s[i].stop > start })
+	return i < len(s) && s[i].start < stop
+}
+
+// spanAtはposを含む範囲を返します。
+func (s protectedSpans) spanAt(pos int) (span, bool) {
+	i := sort.Search(len(s), func(i int) bool { return s[i].stop > pos })
+	if i < len(s) && s[i].start <= pos {
+		return s[i], true
+	}
+	return span{}, false
+}
+
+// withinは[start, stop)全体が1つの保護された範囲に含まれるかを返します。
+func (s protectedSpans) within(start, stop int) bool {
+	i := sort.Search(len(s), func(i int) bool { return s[i].stop > start })
+	return i < len(s) && s[i].start <= start && stop <= s[i].stop
+}
+
+// crossesTextはblock内のテキストノードの範囲を超えて続く保護された範囲があるかを返します。
+// "{% include x param="a *b* c" %}"や複数行のショートコードのように、テンプレート構文が
+// 強調や改行などで複数のテキストノードに分かれている場合にtrueになります。
+func (s protectedSpans) crossesText(block ast.Node) bool {
+	if len(s) == 0 {
+		return false
+	}
+	crosses := false
+	_ = ast.Walk(block, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		node, ok := n.(*ast.Text)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		start, stop := node.Segment.Start, node.Segment.Stop
+		i := sort.Search(len(s), func(i int) bool { return s[i].stop > start })
+		for ; i < len(s) && s[i].start < stop; i++ {
+			if s[i].start < start || s[i].stop > stop {
+				crosses = true
+				return ast.WalkStop, nil
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return crosses
+}
+
+// cutは[start, stop)を保護された範囲の境界で分割し、先頭から順にfnを呼び出します。
+func (s protectedSpans) cut(start, stop int, fn func(start, stop int, protected bool)) {
+	i := sort.Search(len(s), func(i int) bool { return s[i].stop > start })
+	for start < stop {
+		if i >= len(s) || s[i].start >= stop {
+			fn(start, stop, false)
+			return
+		}
+		if s[i].start > start {
+			fn(start, s[i].start, false)
+			start = s[i].start
+		}
+		end := min(s[i].stop, stop)
+		fn(start, end, true)
+		start = end
+		i++
+	}
+}
+
+// encodeProtectedTextはテキストノードの範囲[start, stop)をエスケープし、保護された部分を自己終了タグに置き換えたセグメントを返します。
+// 保護された部分以外に翻訳するテキストがない場合は、翻訳対象外のセグメントを返します。
+func encodeProtectedText(source []byte, start, stop int, protected protectedSpans) Segment {
+	var b strings.Builder
+	var tags []InlineTag
+	hasText := false
+	protected.cut(start, stop, func(start, stop int, protected bool) {
+		value := source[start:stop]
+		if protected {
+			fmt.Fprintf(&b, `<x id="%d"/>`, len(tags))
+			tags = append(tags, InlineTag{Open: string(value), Protected: true})
+			return
+		}
+		if len(bytes.TrimSpace(value)) > 0 {
+			hasText = true
+		}
+		b.WriteString(html.EscapeString(string(value)))
+	})
+	if !hasText {
+		return Segment{Content: string(source[start:stop])}
+	}
+	return Segment{Content: b.String(), IsTranslatable: true, IsXML: true, Tags: tags}
+}
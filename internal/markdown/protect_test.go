@@ -0,0 +1,151 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+// 各プリセットのテンプレート構文は、強調や改行で複数のテキストノードに分かれる場合も
+// 分割されずに1つのプレースホルダタグになり、翻訳後も元のまま残る
+func TestProtectPresets(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset string
+		source string
+		// protectedは分割されずに保護される文字列です。
+		protected []string
+		// textは翻訳されるテキストです。
+		text []string
+	}{
+		{
+			name:      "hugo shortcode",
+			preset:    "hugo",
+			source:    "See {{< ref \"docs/a.md\" >}} for details.\n",
+			protected: []string{`{{< ref "docs/a.md" >}}`},
+			text:      []string{"See", "for details."},
+		},
+		{
+			name:      "hugo paired shortcode",
+			preset:    "hugo",
+			source:    "{{% notice %}}\nText here.\n{{% /notice %}}\n",
+			protected: []string{"{{% notice %}}", "{{% /notice %}}"},
+			text:      []string{"Text here."},
+		},
+		{
+			name:      "hugo multi-line shortcode",
+			preset:    "hugo",
+			source:    "Intro {{< figure src=\"a.png\"\n  title=\"A *title*\" >}} outro.\n",
+			protected: []string{"{{< figure src=\"a.png\"\n  title=\"A *title*\" >}}"},
+			text:      []string{"Intro", "outro."},
+		},
+		{
+			name:      "liquid include with emphasis",
+			preset:    "liquid",
+			source:    "Before {% include note.html param=\"a *b* c\" %} after.\n",
+			protected: []string{`{% include note.html param="a *b* c" %}`},
+			text:      []string{"Before", "after."},
+		},
+		{
+			name:      "liquid output",
+			preset:    "liquid",
+			source:    "Hello {{ page.title }}, welcome.\n",
+			protected: []string{"{{ page.title }}"},
+			text:      []string{"Hello", "welcome."},
+		},
+		{
+			name:      "jinja tags",
+			preset:    "jinja",
+			source:    "{% if user %}Hi {{ user.name }}{% endif %} there.\n",
+			protected: []string{"{% if user %}", "{{ user.name }}", "{% endif %}"},
+			text:      []string{"Hi", "there."},
+		},
+		{
+			name:      "jinja whitespace control with link",
+			preset:    "jinja",
+			source:    "Go {%- set url = \"[a](b)\" -%} home.\n",
+			protected: []string{`{%- set url = "[a](b)" -%}`},
+			text:      []string{"Go", "home."},
+		},
+		{
+			name:      "jekyll link",
+			preset:    "jekyll",
+			source:    "Read {% link _posts/2020-01-01-*intro*.md %} first.\n",
+			protected: []string{"{% link _posts/2020-01-01-*intro*.md %}"},
+			text:      []string{"Read", "first."},
+		},
+		{
+			name:      "docusaurus heading id",
+			preset:    "docusaurus",
+			source:    "## Getting started {#getting-started}\n",
+			protected: []string{"{#getting-started}"},
+			text:      []string{"Getting started"},
+		},
+		{
+			name:      "docusaurus admonition",
+			preset:    "docusaurus",
+			source:    ":::note\nRemember *this*.\n:::\n",
+			protected: []string{":::note", ":::"},
+			text:      []string{"Remember", "this"},
+		},
+	}
+	for _, tt := range tests {
+		for _, mode := range []Mode{ModeText, ModeBlock} {
+			t.Run(tt.name+"/"+modeName(mode), func(t *testing.T) {
+				protection, err := Protection{}.Preset(tt.preset)
+				if err != nil {
+					t.Fatal(err)
+				}
+				segments, err := NewParser(WithMode(mode), WithProtection(protection)).Parse([]byte(tt.source))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := Reconstruct(segments); got != tt.source {
+					t.Fatalf("Reconstruct(Parse(src)) = %q, want %q", got, tt.source)
+				}
+
+				// 保護された文字列は、1つのタグか1つの翻訳対象外のセグメントに含まれる
+				var units []string
+				for i, seg := range segments {
+					if !seg.IsTranslatable {
+						units = append(units, seg.Markdown())
+						continue
+					}
+					for _, tag := range seg.Tags {
+						if tag.Protected {
+							units = append(units, tag.Open)
+						}
+					}
+					segments[i].Content = fakeTranslate(seg.Content, seg.IsXML)
+				}
+				for _, p := range tt.protected {
+					if !containsUnit(units, p) {
+						t.Errorf("%q is split into fragments %q", p, units)
+					}
+				}
+
+				translated := Reconstruct(segments)
+				for _, p := range tt.protected {
+					if !strings.Contains(translated, p) {
+						t.Errorf("protected text %q was changed by translation:\n%s", p, translated)
+					}
+				}
+				for _, s := range tt.text {
+					if !strings.Contains(translated, strings.ToUpper(s)) {
+						t.Errorf("text %q was not translated:\n%s", s, translated)
+					}
+				}
+			})
+		}
+	}
+}
+
+// containsUnitはunitsのいずれかがsを含むかを返します。
+// 隣接する保護された文字列や前後の改行は、1つのタグにまとめられる場合があります。
+func containsUnit(units []string, s string) bool {
+	for _, unit := range units {
+		if strings.Contains(unit, s) {
+			return true
+		}
+	}
+	return false
+}
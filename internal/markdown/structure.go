@@ -0,0 +1,80 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Structureはドキュメントのブロック要素の構造を表します。
+// 各要素は、ネストの深さ、ブロックの種類、種類ごとの属性(見出しのレベルなど)を表す文字列です。
+type Structure []string
+
+// Structureはドキュメントを解析し、ブロック要素の構造を返します。
+// 翻訳結果にMarkdownの記法と解釈される文字列が含まれていないかを、ソースと比較して確認するために使用します。
+// Frontmatterは本文のブロックとして解析せず、その形式のみを構造に含めます。
+func (p *Parser) Structure(source []byte) Structure {
+	var structure Structure
+	kind, length := detectFrontmatter(source)
+	if kind != frontmatterNone {
+		structure = append(structure, fmt.Sprintf("0:Frontmatter(%s)", kind))
+	}
+	reader := text.NewReader(source)
+	reader.Advance(length)
+	doc := p.gm.Parser().Parse(reader)
+
+	depth := 0
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if n.Type() != ast.TypeBlock || n.Kind() == ast.KindDocument {
+			return ast.WalkContinue, nil
+		}
+		if !entering {
+			depth--
+			return ast.WalkContinue, nil
+		}
+		structure = append(structure, fmt.Sprintf("%d:%s", depth, describeBlock(n)))
+		depth++
+		return ast.WalkContinue, nil
+	})
+	return structure
+}
+
+// describeBlockはブロックの種類と、翻訳によって変わってはならない属性を文字列にします。
+func describeBlock(n ast.Node) string {
+	desc := n.Kind().String()
+	switch n := n.(type) {
+	case *ast.Heading:
+		desc += fmt.Sprintf("(level=%d)", n.Level)
+	case *ast.List:
+		desc += fmt.Sprintf("(ordered=%t,marker=%c)", n.IsOrdered(), n.Marker)
+	case *ast.FencedCodeBlock, *ast.CodeBlock, *ast.HTMLBlock, *MathBlock:
+		desc += fmt.Sprintf("(lines=%d)", n.Lines().Len())
+	case *east.Table:
+		desc += fmt.Sprintf("(columns=%d)", len(n.Alignments))
+	}
+	return desc
+}
+
+// Compareは2つの構造を比較し、異なる場合は最初の相違点を含むエラーを返します。
+func (s Structure) Compare(other Structure) error {
+	for i := 0; i < len(s) && i < len(other); i++ {
+		if s[i] != other[i] {
+			return fmt.Errorf("block %d differs: expected %s, got %s", i+1, s[i], other[i])
+		}
+	}
+	switch {
+	case len(s) > len(other):
+		return fmt.Errorf("missing blocks: expected %d, got %d (first missing: %s)", len(s), len(other), s[len(other)])
+	case len(s) < len(other):
+		return fmt.Errorf("unexpected blocks: expected %d, got %d (first unexpected: %s)", len(s), len(other), other[len(s)])
+	}
+	return nil
+}
+
+// Stringは構造を1行に1要素ずつ並べた文字列を返します。
+func (s Structure) String() string {
+	return strings.Join(s, "\n")
+}
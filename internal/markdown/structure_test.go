@@ -0,0 +1,41 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+// 翻訳結果にMarkdownの記法と解釈される文字列が含まれ、ブロック構造が変わった場合はエラーになる
+func TestStructureCompare(t *testing.T) {
+	source := "---\ntitle: A\n---\n# Title\n\nText.\n\n- a\n- b\n\n| a | b |\n| - | - |\n| c | d |\n"
+	tests := []struct {
+		name       string
+		translated string
+		wantErr    string
+	}{
+		{name: "same structure", translated: "---\ntitle: B\n---\n# TITLE\n\nTEXT *EMPHASIS*.\n\n- A\n- B\n\n| A | B |\n| - | - |\n| C | D |\n"},
+		{name: "heading level", translated: "---\ntitle: B\n---\n## TITLE\n\nTEXT.\n\n- A\n- B\n\n| A | B |\n| - | - |\n| C | D |\n", wantErr: "block 2 differs"},
+		{name: "paragraph became a list", translated: "---\ntitle: B\n---\n# TITLE\n\n- TEXT.\n\n- A\n- B\n\n| A | B |\n| - | - |\n| C | D |\n", wantErr: "block 3 differs"},
+		{name: "list marker", translated: "---\ntitle: B\n---\n# TITLE\n\nTEXT.\n\n* A\n* B\n\n| A | B |\n| - | - |\n| C | D |\n", wantErr: "marker=*"},
+		{name: "table columns", translated: "---\ntitle: B\n---\n# TITLE\n\nTEXT.\n\n- A\n- B\n\n| A | B | C |\n| - | - | - |\n| C | D | E |\n", wantErr: "columns=3"},
+		{name: "missing frontmatter", translated: "# TITLE\n\nTEXT.\n\n- A\n- B\n\n| A | B |\n| - | - |\n| C | D |\n", wantErr: "block 1 differs"},
+		{name: "unexpected block", translated: "---\ntitle: B\n---\n# TITLE\n\nTEXT.\n\n---\n\n- A\n- B\n\n| A | B |\n| - | - |\n| C | D |\n", wantErr: "ThematicBreak"},
+		{name: "missing block", translated: "---\ntitle: B\n---\n# TITLE\n\nTEXT.\n\n- A\n- B\n", wantErr: "missing blocks"},
+	}
+	p := NewParser()
+	want := p.Structure([]byte(source))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := want.Compare(p.Structure([]byte(tt.translated)))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Compare() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Compare() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}